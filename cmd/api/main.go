@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	_ "time/tzdata" // Embute a base de fusos IANA, para não depender do sistema operacional
 
+	"github.com/emaildoissa/agenda-flow/internal/agenda"
+	"github.com/emaildoissa/agenda-flow/internal/auth"
 	"github.com/emaildoissa/agenda-flow/internal/handlers"
+	"github.com/emaildoissa/agenda-flow/internal/webhooks"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -28,6 +33,17 @@ func main() {
 		log.Println("AVISO: A variável de ambiente N8N_WEBHOOK_URL não está definida.")
 	}
 
+	// Carrega o segredo usado para assinar os tokens de sessão
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		log.Fatal("A variável de ambiente JWT_SECRET não está definida.")
+	}
+	tokens := auth.NewGerenciadorTokens(jwtSecret)
+
+	// Grava os eventos na outbox com o início do link de autoatendimento
+	// enviado ao cliente (ex.: https://app.exemplo.com/agendamento/)
+	notificador := webhooks.NewNotificador(os.Getenv("LINK_GESTAO_BASE"))
+
 	// <<< INÍCIO DA MODIFICAÇÃO >>>
 	// Carrega as credenciais do banco de dados das variáveis de ambiente
 	dbHost := os.Getenv("DB_HOST")
@@ -50,11 +66,23 @@ func main() {
 	defer db.Close()
 	log.Println("Conexão com o banco de dados bem-sucedida!")
 
+	// Entrega em segundo plano os eventos gravados na outbox
+	despachante := webhooks.NewDespachante(db, n8nURL, os.Getenv("N8N_WEBHOOK_SECRET"))
+	go despachante.Iniciar(context.Background())
+
+	// Cancela as pré-reservas que o salão não confirmou a tempo
+	expirador := agenda.NewExpirador(db, notificador)
+	go expirador.Iniciar(context.Background())
+
+	// Envia os lembretes agendados antes de cada horário
+	lembretes := agenda.NewLembretes(db, notificador)
+	go lembretes.Iniciar(context.Background())
+
 	// Configuração do roteador e middlewares
 	r := chi.NewRouter()
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:3000"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
@@ -64,28 +92,86 @@ func main() {
 	r.Use(middleware.Recoverer)
 
 	// --- Handlers e Rotas ---
+	authHandler := handlers.NewAuthHandler(db, tokens)
+	r.Post("/auth/login", authHandler.Login)
+	r.Post("/auth/refresh", authHandler.Refresh)
+
+	// Rotas de gestão do salão exigem o token do proprietário do próprio salão
+	somenteProprietario := chi.Chain(tokens.Autenticar, auth.ExigirMesmoSalao)
+
 	saloesHandler := handlers.NewSaloesHandler(db)
 	r.Post("/saloes", saloesHandler.CreateSalao)
 	r.Get("/saloes/{idSalao}", saloesHandler.GetSalaoByID)
+	r.With(somenteProprietario...).Put("/saloes/{idSalao}", saloesHandler.UpdateSalao)
+	r.With(somenteProprietario...).Put("/saloes/{idSalao}/horarios", saloesHandler.UpdateHorarios)
+	r.With(somenteProprietario...).Get("/saloes/{idSalao}/configuracoes", saloesHandler.GetConfiguracoes)
+	r.With(somenteProprietario...).Put("/saloes/{idSalao}/configuracoes", saloesHandler.UpdateConfiguracoes)
 
 	servicosHandler := handlers.NewServicosHandler(db)
 	r.Route("/saloes/{idSalao}/servicos", func(r chi.Router) {
-		r.Post("/", servicosHandler.CreateServico)
+		r.With(somenteProprietario...).Post("/", servicosHandler.CreateServico)
 		r.Get("/", servicosHandler.ListServicosBySalaoID)
+		r.With(somenteProprietario...).Put("/ordem", servicosHandler.ReordenarServicos)
+		r.With(somenteProprietario...).Put("/{idServico}", servicosHandler.UpdateServico)
+		r.With(somenteProprietario...).Patch("/{idServico}", servicosHandler.PatchServico)
+		r.With(somenteProprietario...).Delete("/{idServico}", servicosHandler.DeleteServico)
 	})
 
-	// --- ADICIONE ESTAS DUAS LINHAS NOVAMENTE ---
 	funcionariosHandler := handlers.NewFuncionariosHandler(db)
-	r.Get("/saloes/{idSalao}/funcionarios", funcionariosHandler.ListFuncionariosBySalaoID)
-	// --- FIM DA ADIÇÃO ---
+	r.Route("/saloes/{idSalao}/funcionarios", func(r chi.Router) {
+		r.Get("/", funcionariosHandler.ListFuncionariosBySalaoID)
+		r.With(somenteProprietario...).Post("/", funcionariosHandler.CreateFuncionario)
+		r.With(somenteProprietario...).Put("/{idFuncionario}", funcionariosHandler.UpdateFuncionario)
+		r.With(somenteProprietario...).Delete("/{idFuncionario}", funcionariosHandler.DeleteFuncionario)
+		r.Get("/{idFuncionario}/servicos", funcionariosHandler.ListServicosDoFuncionario)
+		r.With(somenteProprietario...).Put("/{idFuncionario}/servicos", funcionariosHandler.DefinirServicosDoFuncionario)
+		r.With(somenteProprietario...).Delete("/{idFuncionario}/servicos/{idServico}", funcionariosHandler.RemoverServicoDoFuncionario)
+	})
 
-	agendamentosHandler := handlers.NewAgendamentosHandler(db, n8nURL)
+	agendamentosHandler := handlers.NewAgendamentosHandler(db, notificador)
 	r.Post("/agendamentos", agendamentosHandler.CreateAgendamento)
-	r.Put("/agendamentos/{idAgendamento}/confirmar", agendamentosHandler.ConfirmarAgendamento)
-	r.Put("/agendamentos/{idAgendamento}/cancelar", agendamentosHandler.CancelarAgendamento)
+	r.With(tokens.Autenticar).Put("/agendamentos/{idAgendamento}/confirmar", agendamentosHandler.ConfirmarAgendamento)
+	r.With(tokens.Autenticar).Put("/agendamentos/{idAgendamento}/cancelar", agendamentosHandler.CancelarAgendamento)
+	r.With(tokens.Autenticar).Put("/agendamentos/{idAgendamento}/concluir", agendamentosHandler.ConcluirAgendamento)
+	r.With(tokens.Autenticar).Put("/agendamentos/{idAgendamento}/nao-compareceu", agendamentosHandler.MarcarNaoCompareceu)
+	r.With(tokens.Autenticar).Put("/agendamentos/{idAgendamento}/reagendar", agendamentosHandler.ReagendarAgendamento)
+	r.With(tokens.Autenticar).Get("/agendamentos/{idAgendamento}/historico", agendamentosHandler.GetHistorico)
+
+	// Agenda do salão para o proprietário
+	r.With(somenteProprietario...).Get("/saloes/{idSalao}/agendamentos", agendamentosHandler.ListAgendamentos)
+	r.With(somenteProprietario...).Get("/saloes/{idSalao}/agendamentos/dia", agendamentosHandler.GetAgendaDoDia)
+
+	// Autoatendimento do cliente pelo token de gestão (sem login)
+	r.Get("/agendamentos/gestao/{token}", agendamentosHandler.GetPorToken)
+	r.Post("/agendamentos/gestao/{token}/cancelar", agendamentosHandler.CancelarPorToken)
+	r.Post("/agendamentos/gestao/{token}/reagendar", agendamentosHandler.ReagendarPorToken)
+
+	excecoesHandler := handlers.NewExcecoesHandler(db)
+	r.Route("/saloes/{idSalao}/excecoes", func(r chi.Router) {
+		r.Get("/", excecoesHandler.ListExcecoes)
+		r.With(somenteProprietario...).Post("/", excecoesHandler.SalvarExcecao)
+		r.With(somenteProprietario...).Delete("/{idExcecao}", excecoesHandler.DeleteExcecao)
+		r.With(somenteProprietario...).Post("/feriados", excecoesHandler.ImportarFeriados)
+	})
 
 	disponibilidadeHandler := handlers.NewDisponibilidadeHandler(db)
 	r.Get("/saloes/{idSalao}/disponibilidade", disponibilidadeHandler.GetDisponibilidade)
+	r.Get("/saloes/{idSalao}/disponibilidade/periodo", disponibilidadeHandler.GetDisponibilidadePeriodo)
+
+	webhooksHandler := handlers.NewWebhooksHandler(db)
+	r.Route("/saloes/{idSalao}/webhooks", func(r chi.Router) {
+		r.Use(somenteProprietario...)
+		r.Post("/", webhooksHandler.CreateEndpoint)
+		r.Get("/", webhooksHandler.ListEndpoints)
+		r.Put("/{idWebhook}", webhooksHandler.UpdateEndpoint)
+		r.Delete("/{idWebhook}", webhooksHandler.DeleteEndpoint)
+		r.Get("/{idWebhook}/entregas", webhooksHandler.ListEntregas)
+		r.Get("/falhas", webhooksHandler.ListFalhas)
+		r.Post("/falhas/{idEvento}/reenviar", webhooksHandler.ReenviarFalha)
+	})
+
+	relatoriosHandler := handlers.NewRelatoriosHandler(db)
+	r.With(somenteProprietario...).Get("/saloes/{idSalao}/relatorios/cancelamentos-tardios", relatoriosHandler.ListCancelamentosTardios)
 
 	r.Get("/healthcheck", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,95 @@
+package agenda
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/models"
+	"github.com/emaildoissa/agenda-flow/internal/webhooks"
+)
+
+// MotivoExpiracao é gravado no histórico das pré-reservas canceladas automaticamente.
+const MotivoExpiracao = "Pré-reserva expirada sem confirmação do salão"
+
+// Expirador cancela as pré-reservas (PENDENTE) cujo prazo de confirmação
+// passou, liberando o horário e avisando o cliente.
+type Expirador struct {
+	DB          *sql.DB
+	Notificador *webhooks.Notificador
+	Intervalo   time.Duration
+	TamanhoLote int
+}
+
+// NewExpirador cria um expirador que verifica as pré-reservas a cada 30 segundos.
+func NewExpirador(db *sql.DB, notificador *webhooks.Notificador) *Expirador {
+	return &Expirador{DB: db, Notificador: notificador, Intervalo: 30 * time.Second, TamanhoLote: 50}
+}
+
+// Iniciar roda o expirador até o contexto ser cancelado.
+func (e *Expirador) Iniciar(ctx context.Context) {
+	ticker := time.NewTicker(e.Intervalo)
+	defer ticker.Stop()
+	for {
+		if err := e.expirarLote(ctx); err != nil {
+			log.Printf("Erro ao expirar pré-reservas: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expirarLote cancela um lote de pré-reservas vencidas. O SKIP LOCKED evita
+// que duas réplicas da API processem o mesmo agendamento.
+func (e *Expirador) expirarLote(ctx context.Context) error {
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, salao_id FROM agendamentos
+		WHERE status = $1 AND expira_em <= NOW()
+		ORDER BY expira_em
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`,
+		models.StatusPendente, e.TamanhoLote)
+	if err != nil {
+		return err
+	}
+	var vencidos []Alteracao
+	for rows.Next() {
+		alt := Alteracao{
+			NovoStatus:  models.StatusCancelado,
+			Motivo:      MotivoExpiracao,
+			AlteradoPor: AutorSistema,
+			Evento:      webhooks.EventoAgendamentoExpirado,
+			Notificador: e.Notificador,
+		}
+		if err := rows.Scan(&alt.AgendamentoID, &alt.SalaoID); err != nil {
+			rows.Close()
+			return err
+		}
+		vencidos = append(vencidos, alt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, alt := range vencidos {
+		if _, err := Transicionar(ctx, tx, alt); err != nil {
+			return err
+		}
+	}
+	if len(vencidos) > 0 {
+		log.Printf("%d pré-reserva(s) expirada(s) e cancelada(s).", len(vencidos))
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,144 @@
+package agenda
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/models"
+	"github.com/emaildoissa/agenda-flow/internal/webhooks"
+)
+
+// Status de um lembrete agendado
+const (
+	LembretePendente   = "PENDENTE"
+	LembreteEnviado    = "ENVIADO"
+	LembreteDescartado = "DESCARTADO" // O agendamento deixou de estar ativo antes do envio
+)
+
+// AgendarLembretes (re)cria os lembretes do agendamento a partir das
+// antecedências configuradas no salão. Deve ser chamado na mesma transação
+// que cria ou reagenda o agendamento. Lembretes cujo horário já passou não são
+// criados; os já enviados voltam a valer se o agendamento mudou de horário.
+func AgendarLembretes(ctx context.Context, tx *sql.Tx, agendamentoID int) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM agendamento_lembretes WHERE agendamento_id = $1 AND status = $2",
+		agendamentoID, LembretePendente)
+	if err != nil {
+		return fmt.Errorf("erro ao remover lembretes do agendamento %d: %w", agendamentoID, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO agendamento_lembretes (agendamento_id, antecedencia_minutos, enviar_em)
+		SELECT a.id, m, a.data_hora_inicio - make_interval(mins => m)
+		FROM agendamentos a
+		JOIN saloes s ON s.id = a.salao_id
+		CROSS JOIN unnest(s.lembretes_minutos) AS m
+		WHERE a.id = $1 AND a.data_hora_inicio - make_interval(mins => m) > NOW()
+		ON CONFLICT (agendamento_id, antecedencia_minutos)
+		DO UPDATE SET enviar_em = EXCLUDED.enviar_em, status = $2, processado_em = NULL`,
+		agendamentoID, LembretePendente)
+	if err != nil {
+		return fmt.Errorf("erro ao agendar lembretes do agendamento %d: %w", agendamentoID, err)
+	}
+	return nil
+}
+
+// Lembretes envia o evento agendamento.lembrete quando chega a hora de cada
+// lembrete agendado. Como os lembretes ficam no banco, nada se perde se a API
+// reiniciar; o que venceu nesse meio tempo é enviado na próxima rodada.
+type Lembretes struct {
+	DB          *sql.DB
+	Notificador *webhooks.Notificador
+	Intervalo   time.Duration
+	TamanhoLote int
+}
+
+// NewLembretes cria o agendador de lembretes, que verifica a fila a cada 30 segundos.
+func NewLembretes(db *sql.DB, notificador *webhooks.Notificador) *Lembretes {
+	return &Lembretes{DB: db, Notificador: notificador, Intervalo: 30 * time.Second, TamanhoLote: 50}
+}
+
+// Iniciar roda o agendador até o contexto ser cancelado.
+func (l *Lembretes) Iniciar(ctx context.Context) {
+	ticker := time.NewTicker(l.Intervalo)
+	defer ticker.Stop()
+	for {
+		if err := l.enviarLote(ctx); err != nil {
+			log.Printf("Erro ao enviar lembretes: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type lembreteVencido struct {
+	id                  int64
+	agendamentoID       int
+	antecedenciaMinutos int
+	status              string
+	inicio              time.Time
+}
+
+// enviarLote processa um lote de lembretes vencidos. O SKIP LOCKED garante que
+// cada lembrete seja enviado por uma única réplica da API.
+func (l *Lembretes) enviarLote(ctx context.Context) error {
+	tx, err := l.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT lm.id, lm.agendamento_id, lm.antecedencia_minutos, a.status, a.data_hora_inicio
+		FROM agendamento_lembretes lm
+		JOIN agendamentos a ON a.id = lm.agendamento_id
+		WHERE lm.status = $1 AND lm.enviar_em <= NOW()
+		ORDER BY lm.enviar_em
+		LIMIT $2
+		FOR UPDATE OF lm SKIP LOCKED`,
+		LembretePendente, l.TamanhoLote)
+	if err != nil {
+		return err
+	}
+	var vencidos []lembreteVencido
+	for rows.Next() {
+		var v lembreteVencido
+		if err := rows.Scan(&v.id, &v.agendamentoID, &v.antecedenciaMinutos, &v.status, &v.inicio); err != nil {
+			rows.Close()
+			return err
+		}
+		vencidos = append(vencidos, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	enviados := 0
+	for _, v := range vencidos {
+		// Agendamentos cancelados (ou que já começaram, se a API ficou fora do
+		// ar por muito tempo) não recebem lembrete
+		status := LembreteDescartado
+		if models.StatusAtivo(v.status) && time.Now().Before(v.inicio) {
+			if err := l.Notificador.NotificarLembrete(ctx, tx, v.agendamentoID, v.antecedenciaMinutos); err != nil {
+				return err
+			}
+			status = LembreteEnviado
+			enviados++
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE agendamento_lembretes SET status = $1, processado_em = NOW() WHERE id = $2", status, v.id)
+		if err != nil {
+			return err
+		}
+	}
+	if enviados > 0 {
+		log.Printf("%d lembrete(s) enfileirado(s).", enviados)
+	}
+
+	return tx.Commit()
+}
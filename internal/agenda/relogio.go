@@ -0,0 +1,14 @@
+package agenda
+
+import "time"
+
+// Relogio fornece a hora atual. As regras que dependem do momento da reserva
+// recebem um Relogio para poderem ser testadas com um horário fixo.
+type Relogio interface {
+	Agora() time.Time
+}
+
+// RelogioDoSistema é o Relogio usado em produção.
+type RelogioDoSistema struct{}
+
+func (RelogioDoSistema) Agora() time.Time { return time.Now() }
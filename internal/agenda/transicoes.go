@@ -0,0 +1,148 @@
+package agenda
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/models"
+	"github.com/emaildoissa/agenda-flow/internal/webhooks"
+)
+
+var (
+	ErrAgendamentoNaoEncontrado = errors.New("agendamento não encontrado")
+	ErrTransicaoInvalida        = errors.New("transição de status inválida")
+	ErrForaDoPrazo              = errors.New("fora do prazo da política de cancelamento")
+)
+
+// Autores usados no histórico quando a alteração não vem do proprietário
+const (
+	AutorCliente = "cliente"
+	AutorSistema = "sistema"
+)
+
+// AutorProprietario identifica no histórico o proprietário do salão.
+func AutorProprietario(salaoID int) string {
+	return "salao:" + strconv.Itoa(salaoID)
+}
+
+// eventoDoStatus diz qual evento é emitido quando o agendamento passa para o status
+var eventoDoStatus = map[string]string{
+	models.StatusConfirmado:    webhooks.EventoAgendamentoConfirmado,
+	models.StatusCancelado:     webhooks.EventoAgendamentoCancelado,
+	models.StatusConcluido:     webhooks.EventoAgendamentoConcluido,
+	models.StatusNaoCompareceu: webhooks.EventoAgendamentoNaoCompareceu,
+}
+
+// Alteracao descreve uma mudança de status pedida para um agendamento.
+type Alteracao struct {
+	AgendamentoID int
+	SalaoID       int
+	NovoStatus    string
+	Motivo        string
+	AlteradoPor   string
+	Evento        string // Opcional: substitui o evento padrão do novo status
+
+	// ExigirAntecedencia recusa (ErrForaDoPrazo) cancelamentos do cliente feitos
+	// com menos antecedência do que a política do salão pede. Sem ela, o
+	// cancelamento é aceito mas fica registrado como tardio contra o cliente.
+	ExigirAntecedencia bool
+
+	// AtribuidoAoCliente marca um cancelamento feito pelo salão como pedido do
+	// cliente (ex.: o cliente desistiu por telefone), para contar como tardio.
+	AtribuidoAoCliente bool
+
+	Relogio     Relogio               // Hora usada no prazo da política; nil usa o relógio do sistema
+	Notificador *webhooks.Notificador // Grava o evento do webhook na outbox
+}
+
+// Transicionar aplica a mudança de status dentro da transação, validando a
+// máquina de estados, gravando o histórico e enfileirando o evento do webhook.
+// Devolve ErrAgendamentoNaoEncontrado se o agendamento não for do salão e
+// ErrTransicaoInvalida se a mudança não for permitida a partir do status atual.
+func Transicionar(ctx context.Context, tx *sql.Tx, alt Alteracao) (string, error) {
+	var statusAtual string
+	var inicio time.Time
+	var antecedenciaHoras int
+	err := tx.QueryRowContext(ctx, `
+		SELECT a.status, a.data_hora_inicio, s.antecedencia_minima_cancelamento_horas
+		FROM agendamentos a
+		JOIN saloes s ON s.id = a.salao_id
+		WHERE a.id = $1 AND a.salao_id = $2
+		FOR UPDATE OF a`,
+		alt.AgendamentoID, alt.SalaoID).Scan(&statusAtual, &inicio, &antecedenciaHoras)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrAgendamentoNaoEncontrado
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !models.PodeTransicionar(statusAtual, alt.NovoStatus) {
+		return statusAtual, fmt.Errorf("%w: de %s para %s", ErrTransicaoInvalida, statusAtual, alt.NovoStatus)
+	}
+
+	// Só contam contra o cliente os cancelamentos dele ou atribuídos a ele pelo
+	// salão; os do próprio salão e os automáticos (expiração) nunca contam
+	tardio := false
+	doCliente := alt.AlteradoPor == AutorCliente || alt.AtribuidoAoCliente
+	if alt.NovoStatus == models.StatusCancelado && doCliente {
+		relogio := alt.Relogio
+		if relogio == nil {
+			relogio = RelogioDoSistema{}
+		}
+		tardio = DentroDoPrazoMinimo(inicio, antecedenciaHoras, relogio.Agora())
+		if tardio && alt.ExigirAntecedencia {
+			return statusAtual, fmt.Errorf("%w: cancelamentos exigem %d hora(s) de antecedência", ErrForaDoPrazo, antecedenciaHoras)
+		}
+	}
+
+	// Ao sair de PENDENTE a pré-reserva deixa de expirar
+	_, err = tx.ExecContext(ctx, "UPDATE agendamentos SET status = $1, expira_em = NULL, cancelamento_tardio = $2 WHERE id = $3",
+		alt.NovoStatus, tardio, alt.AgendamentoID)
+	if err != nil {
+		return statusAtual, err
+	}
+	// Os serviços só ocupam a agenda enquanto o agendamento está ativo
+	_, err = tx.ExecContext(ctx, "UPDATE agendamento_servicos SET ativo = $1 WHERE agendamento_id = $2",
+		models.StatusAtivo(alt.NovoStatus), alt.AgendamentoID)
+	if err != nil {
+		return statusAtual, err
+	}
+
+	if err := RegistrarHistorico(ctx, tx, alt.AgendamentoID, &statusAtual, alt.NovoStatus, alt.Motivo, alt.AlteradoPor); err != nil {
+		return statusAtual, err
+	}
+
+	evento := alt.Evento
+	if evento == "" {
+		evento = eventoDoStatus[alt.NovoStatus]
+	}
+	return statusAtual, alt.Notificador.NotificarAgendamento(ctx, tx, evento, alt.AgendamentoID, nil)
+}
+
+// DentroDoPrazoMinimo diz se, no momento agora, já passou o limite para
+// cancelar ou reagendar um agendamento que começa em inicio.
+func DentroDoPrazoMinimo(inicio time.Time, antecedenciaHoras int, agora time.Time) bool {
+	return agora.Add(time.Duration(antecedenciaHoras) * time.Hour).After(inicio)
+}
+
+// RegistrarHistorico grava uma linha em agendamento_historico. statusAnterior é
+// nulo na criação; em reagendamentos, anterior e novo são iguais.
+func RegistrarHistorico(ctx context.Context, tx *sql.Tx, agendamentoID int, statusAnterior *string, statusNovo, motivo, alteradoPor string) error {
+	var motivoSQL *string
+	if motivo != "" {
+		motivoSQL = &motivo
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO agendamento_historico (agendamento_id, status_anterior, status_novo, motivo, alterado_por)
+		VALUES ($1, $2, $3, $4, $5)`,
+		agendamentoID, statusAnterior, statusNovo, motivoSQL, alteradoPor)
+	if err != nil {
+		return fmt.Errorf("erro ao gravar histórico do agendamento %d: %w", agendamentoID, err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package agenda
+
+import (
+	"testing"
+	"time"
+)
+
+func carregarFuso(t *testing.T, nome string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(nome)
+	if err != nil {
+		t.Fatalf("fuso %s indisponível: %v", nome, err)
+	}
+	return loc
+}
+
+// relogioFixo devolve sempre o mesmo instante.
+type relogioFixo time.Time
+
+func (r relogioFixo) Agora() time.Time { return time.Time(r) }
+
+func TestDentroDoPrazoMinimo(t *testing.T) {
+	sp := carregarFuso(t, "America/Sao_Paulo")
+	inicio := time.Date(2025, 3, 10, 14, 0, 0, 0, sp)
+
+	casos := []struct {
+		nome    string
+		horas   int
+		relogio Relogio
+		tardio  bool
+	}{
+		{"sem política nunca é tardio", 0, relogioFixo(inicio.Add(-time.Minute)), false},
+		{"exatamente no prazo", 24, relogioFixo(inicio.Add(-24 * time.Hour)), false},
+		{"um minuto depois do prazo", 24, relogioFixo(inicio.Add(-24*time.Hour + time.Minute)), true},
+		{"com folga", 24, relogioFixo(inicio.Add(-48 * time.Hour)), false},
+		{"depois do início", 2, relogioFixo(inicio.Add(time.Hour)), true},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			if got := DentroDoPrazoMinimo(inicio, c.horas, c.relogio.Agora()); got != c.tardio {
+				t.Errorf("DentroDoPrazoMinimo = %v, esperado %v", got, c.tardio)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type chaveContexto string
+
+const chaveSalaoID chaveContexto = "salao_id"
+
+// Autenticar exige um access token válido no cabeçalho Authorization
+// e injeta o ID do salão autenticado no contexto da requisição.
+func (g *GerenciadorTokens) Autenticar(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cabecalho := r.Header.Get("Authorization")
+		token, ok := strings.CutPrefix(cabecalho, "Bearer ")
+		if !ok || token == "" {
+			http.Error(w, "Autenticação necessária", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := g.Validar(token, TipoAcesso)
+		if err != nil {
+			http.Error(w, "Token inválido ou expirado", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), chaveSalaoID, claims.SalaoID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ExigirMesmoSalao bloqueia o acesso a rotas /saloes/{idSalao}/... de outro salão.
+// Deve ser usado depois de Autenticar.
+func ExigirMesmoSalao(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		salaoID, ok := SalaoIDDoContexto(r.Context())
+		if !ok {
+			http.Error(w, "Autenticação necessária", http.StatusUnauthorized)
+			return
+		}
+
+		salaoIDURL, err := strconv.Atoi(chi.URLParam(r, "idSalao"))
+		if err != nil {
+			http.Error(w, "ID de salão inválido", http.StatusBadRequest)
+			return
+		}
+		if salaoIDURL != salaoID {
+			http.Error(w, "Acesso negado a este salão", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SalaoIDDoContexto devolve o salão autenticado, se houver.
+func SalaoIDDoContexto(ctx context.Context) (int, bool) {
+	salaoID, ok := ctx.Value(chaveSalaoID).(int)
+	return salaoID, ok
+}
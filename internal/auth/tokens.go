@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	TipoAcesso  = "acesso"
+	TipoRefresh = "refresh"
+)
+
+var (
+	ErrTokenInvalido = errors.New("token inválido")
+	ErrTokenExpirado = errors.New("token expirado")
+)
+
+// Claims são os dados que carregamos dentro do JWT.
+type Claims struct {
+	SalaoID   int    `json:"salao_id"`
+	Tipo      string `json:"tipo"`
+	EmitidoEm int64  `json:"iat"`
+	ExpiraEm  int64  `json:"exp"`
+}
+
+// ParDeTokens é o que devolvemos no login e no refresh.
+type ParDeTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// GerenciadorTokens emite e valida JWTs assinados com HMAC-SHA256.
+type GerenciadorTokens struct {
+	segredo        []byte
+	DuracaoAcesso  time.Duration
+	DuracaoRefresh time.Duration
+}
+
+// NewGerenciadorTokens cria um gerenciador com as durações padrão
+// (15 minutos para o access token e 7 dias para o refresh token).
+func NewGerenciadorTokens(segredo string) *GerenciadorTokens {
+	return &GerenciadorTokens{
+		segredo:        []byte(segredo),
+		DuracaoAcesso:  15 * time.Minute,
+		DuracaoRefresh: 7 * 24 * time.Hour,
+	}
+}
+
+// GerarPar emite um access token e um refresh token para o salão.
+func (g *GerenciadorTokens) GerarPar(salaoID int) (ParDeTokens, error) {
+	agora := time.Now()
+	acesso, err := g.assinar(Claims{SalaoID: salaoID, Tipo: TipoAcesso, EmitidoEm: agora.Unix(), ExpiraEm: agora.Add(g.DuracaoAcesso).Unix()})
+	if err != nil {
+		return ParDeTokens{}, err
+	}
+	refresh, err := g.assinar(Claims{SalaoID: salaoID, Tipo: TipoRefresh, EmitidoEm: agora.Unix(), ExpiraEm: agora.Add(g.DuracaoRefresh).Unix()})
+	if err != nil {
+		return ParDeTokens{}, err
+	}
+	return ParDeTokens{
+		AccessToken:  acesso,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(g.DuracaoAcesso.Seconds()),
+	}, nil
+}
+
+// Validar confere a assinatura, a expiração e o tipo do token.
+func (g *GerenciadorTokens) Validar(token, tipoEsperado string) (*Claims, error) {
+	partes := strings.Split(token, ".")
+	if len(partes) != 3 {
+		return nil, ErrTokenInvalido
+	}
+
+	assinaturaEsperada := g.calcularAssinatura(partes[0] + "." + partes[1])
+	assinatura, err := base64.RawURLEncoding.DecodeString(partes[2])
+	if err != nil || !hmac.Equal(assinatura, assinaturaEsperada) {
+		return nil, ErrTokenInvalido
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(partes[1])
+	if err != nil {
+		return nil, ErrTokenInvalido
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrTokenInvalido
+	}
+
+	if claims.Tipo != tipoEsperado || claims.SalaoID == 0 {
+		return nil, ErrTokenInvalido
+	}
+	if time.Now().Unix() >= claims.ExpiraEm {
+		return nil, ErrTokenExpirado
+	}
+	return &claims, nil
+}
+
+func (g *GerenciadorTokens) assinar(claims Claims) (string, error) {
+	cabecalho := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payloadBytes, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(payloadBytes)
+	assinatura := base64.RawURLEncoding.EncodeToString(g.calcularAssinatura(cabecalho + "." + payload))
+	return cabecalho + "." + payload + "." + assinatura, nil
+}
+
+func (g *GerenciadorTokens) calcularAssinatura(conteudo string) []byte {
+	mac := hmac.New(sha256.New, g.segredo)
+	mac.Write([]byte(conteudo))
+	return mac.Sum(nil)
+}
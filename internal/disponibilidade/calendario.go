@@ -0,0 +1,183 @@
+// Package disponibilidade é o motor que decide quais horários um salão pode
+// oferecer. Ele não acessa o banco: recebe o calendário, as configurações e os
+// agendamentos já carregados, e é usado tanto para listar horários livres quanto
+// para validar um agendamento antes de gravá-lo.
+package disponibilidade
+
+import (
+	"errors"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/models"
+)
+
+var (
+	ErrSalaoFechado     = errors.New("o salão não funciona neste dia")
+	ErrForaDoExpediente = errors.New("horário fora do expediente do salão")
+	ErrPausa            = errors.New("horário coincide com uma pausa do salão")
+	ErrForaDoTurno      = errors.New("o profissional não atende neste horário")
+)
+
+// Calendario reúne o expediente semanal do salão e as exceções por data
+// (feriados, folgas, horários especiais), que têm precedência sobre ele.
+type Calendario struct {
+	Semanal  models.HorarioSemanal
+	Loc      *time.Location
+	excecoes map[chaveExcecao]models.ExcecaoHorario
+}
+
+// chaveExcecao identifica uma exceção pela data local e pelo profissional
+// (0 quando vale para o salão todo).
+type chaveExcecao struct {
+	data          string
+	funcionarioID int
+}
+
+// NovoCalendario cria o calendário de um salão, ainda sem exceções.
+func NovoCalendario(semanal models.HorarioSemanal, loc *time.Location) *Calendario {
+	return &Calendario{Semanal: semanal, Loc: loc, excecoes: make(map[chaveExcecao]models.ExcecaoHorario)}
+}
+
+// AdicionarExcecao registra uma exceção do salão ou de um profissional.
+func (c *Calendario) AdicionarExcecao(e models.ExcecaoHorario) {
+	chave := chaveExcecao{data: e.Data}
+	if e.FuncionarioID != nil {
+		chave.funcionarioID = *e.FuncionarioID
+	}
+	c.excecoes[chave] = e
+}
+
+// DiaDoSalao devolve o expediente do salão na data, ou nil se estiver fechado.
+func (c *Calendario) DiaDoSalao(data time.Time) *models.HorarioDia {
+	if e, ok := c.excecoes[chaveExcecao{data: data.Format("2006-01-02")}]; ok {
+		return e.HorarioDoDia()
+	}
+	return HorarioDaSemana(c.Semanal, data)
+}
+
+// DiaDoFuncionario devolve o horário em que o profissional atende na data:
+// o trecho comum entre o expediente do salão e o turno dele, que vem da
+// exceção do profissional para a data, do turno semanal ou, sem nenhum dos
+// dois, do próprio expediente do salão.
+func (c *Calendario) DiaDoFuncionario(data time.Time, f models.Funcionario) *models.HorarioDia {
+	salao := c.DiaDoSalao(data)
+	if salao == nil {
+		return nil
+	}
+	if e, ok := c.excecoes[chaveExcecao{data: data.Format("2006-01-02"), funcionarioID: f.ID}]; ok {
+		return intersecao(salao, e.HorarioDoDia())
+	}
+	if f.Horarios == nil {
+		return salao
+	}
+	return intersecao(salao, HorarioDaSemana(f.Horarios, data))
+}
+
+// Validar confere se o intervalo cabe no expediente do dia e não invade
+// nenhuma pausa, no salão e, se f não for nulo, na agenda do profissional.
+// Para um serviço já encaixado, o intervalo é o trecho bloqueado, com a
+// preparação e a limpeza: elas também não podem passar do fechamento nem
+// invadir uma pausa.
+// Devolve ErrSalaoFechado, ErrForaDoExpediente, ErrPausa ou ErrForaDoTurno.
+func (c *Calendario) Validar(inicio, fim time.Time, f *models.Funcionario) error {
+	data := inicio.In(c.Loc)
+	horario := c.DiaDoSalao(data)
+	if horario == nil {
+		return ErrSalaoFechado
+	}
+	if !cabeNoHorario(data, horario, inicio, fim) {
+		return ErrForaDoExpediente
+	}
+	if cruzaPausa(data, horario, inicio, fim) {
+		return ErrPausa
+	}
+	if f == nil {
+		return nil
+	}
+	turno := c.DiaDoFuncionario(data, *f)
+	if turno == nil || !cabeNoHorario(data, turno, inicio, fim) || cruzaPausa(data, turno, inicio, fim) {
+		return ErrForaDoTurno
+	}
+	return nil
+}
+
+// intersecao devolve o trecho comum a dois expedientes, somando as pausas de
+// ambos, ou nil se não houver sobreposição.
+func intersecao(a, b *models.HorarioDia) *models.HorarioDia {
+	if a == nil || b == nil {
+		return nil
+	}
+	// Horários "HH:MM" com zero à esquerda podem ser comparados como texto
+	h := &models.HorarioDia{Inicio: max(a.Inicio, b.Inicio), Fim: min(a.Fim, b.Fim)}
+	if h.Inicio >= h.Fim {
+		return nil
+	}
+	h.Pausas = append(append(h.Pausas, a.Pausas...), b.Pausas...)
+	return h
+}
+
+// HorarioDaSemana devolve o expediente configurado para o dia da semana da data,
+// ou nil se não houver atendimento nesse dia.
+func HorarioDaSemana(todosHorarios models.HorarioSemanal, data time.Time) *models.HorarioDia {
+	return todosHorarios[DiaDaSemana(data)]
+}
+
+// DiaDaSemana devolve a chave do dia da semana usada nos horários ("segunda", ...).
+func DiaDaSemana(data time.Time) string {
+	return DiasDaSemana[data.Weekday()]
+}
+
+// DiasDaSemana são as chaves aceitas nos horários semanais, na ordem de time.Weekday.
+var DiasDaSemana = [...]string{"domingo", "segunda", "terca", "quarta", "quinta", "sexta", "sabado"}
+
+// MeiaNoite devolve o primeiro instante, no fuso loc, do dia de calendário de
+// data (ano, mês e dia tomados da própria data, sem conversão de fuso). Onde o
+// horário de verão começa à meia-noite (como em Santiago, ou no Brasil até 2019)
+// a meia-noite não existe e time.Date pode cair na véspera; nesse caso avança
+// até o dia começar.
+func MeiaNoite(data time.Time, loc *time.Location) time.Time {
+	inicio := time.Date(data.Year(), data.Month(), data.Day(), 0, 0, 0, 0, loc)
+	meioDia := time.Date(data.Year(), data.Month(), data.Day(), 12, 0, 0, 0, loc)
+	if inicio.Day() != meioDia.Day() {
+		_, antes := inicio.Zone()
+		_, depois := meioDia.Zone()
+		inicio = inicio.Add(time.Duration(depois-antes) * time.Second)
+	}
+	return inicio
+}
+
+// SomarDias devolve o início do dia de calendário n dias depois de dia, no fuso
+// de dia. A conta é feita na data, e não somando 24 horas nem com AddDate, que
+// também cairia na véspera quando a meia-noite do dia de destino não existe.
+func SomarDias(dia time.Time, n int) time.Time {
+	return MeiaNoite(time.Date(dia.Year(), dia.Month(), dia.Day()+n, 0, 0, 0, 0, time.UTC), dia.Location())
+}
+
+// HorarioNaData converte um horário "HH:MM" para o instante correspondente na data,
+// no fuso da própria data. Horários que não existem por causa do horário de verão
+// são normalizados por time.Date.
+func HorarioNaData(data time.Time, hhmm string) time.Time {
+	parsed, _ := time.Parse("15:04", hhmm)
+	return time.Date(data.Year(), data.Month(), data.Day(), parsed.Hour(), parsed.Minute(), 0, 0, data.Location())
+}
+
+// cabeNoHorario diz se o intervalo [inicio, fim) está dentro do expediente.
+func cabeNoHorario(data time.Time, horario *models.HorarioDia, inicio, fim time.Time) bool {
+	return !inicio.Before(HorarioNaData(data, horario.Inicio)) && !fim.After(HorarioNaData(data, horario.Fim))
+}
+
+// cruzaPausa diz se o intervalo [inicio, fim) invade alguma pausa do expediente.
+func cruzaPausa(data time.Time, horario *models.HorarioDia, inicio, fim time.Time) bool {
+	for _, pausa := range horario.Pausas {
+		if sobrepoe(inicio, fim, HorarioNaData(data, pausa.Inicio), HorarioNaData(data, pausa.Fim)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sobrepoe diz se os intervalos [inicioA, fimA) e [inicioB, fimB) se cruzam.
+// É a mesma regra usada pela constraint agendamento_servicos_sem_sobreposicao no banco.
+func sobrepoe(inicioA, fimA, inicioB, fimB time.Time) bool {
+	return inicioA.Before(fimB) && fimA.After(inicioB)
+}
@@ -0,0 +1,114 @@
+package disponibilidade
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/models"
+)
+
+func TestCalendarioValidar(t *testing.T) {
+	data := segunda(t)
+	cal := NovoCalendario(models.HorarioSemanal{
+		"segunda": {Inicio: "09:00", Fim: "18:00", Pausas: []models.Pausa{{Inicio: "12:00", Fim: "13:00"}}},
+		"terca":   {Inicio: "09:00", Fim: "18:00"},
+	}, data.Location())
+	cal.AdicionarExcecao(models.ExcecaoHorario{Data: "2025-03-11", Fechado: true, Descricao: "Carnaval"})
+	ana := models.Funcionario{ID: 1, Horarios: models.HorarioSemanal{"segunda": {Inicio: "13:00", Fim: "18:00"}}}
+
+	casos := []struct {
+		nome, inicio, fim string
+		data              time.Time
+		funcionario       *models.Funcionario
+		esperado          error
+	}{
+		{nome: "dentro do expediente", inicio: "09:00", fim: "10:00", data: data},
+		{nome: "termina no início da pausa", inicio: "11:00", fim: "12:00", data: data},
+		{nome: "invade a pausa", inicio: "11:30", fim: "12:30", data: data, esperado: ErrPausa},
+		{nome: "passa do fechamento", inicio: "17:30", fim: "18:30", data: data, esperado: ErrForaDoExpediente},
+		{nome: "antes da abertura", inicio: "08:30", fim: "09:30", data: data, esperado: ErrForaDoExpediente},
+		{nome: "dia sem expediente", inicio: "10:00", fim: "11:00", data: data.AddDate(0, 0, -1), esperado: ErrSalaoFechado},
+		{nome: "exceção fecha o dia", inicio: "10:00", fim: "11:00", data: data.AddDate(0, 0, 1), esperado: ErrSalaoFechado},
+		{nome: "dentro do turno do profissional", inicio: "14:00", fim: "15:00", data: data, funcionario: &ana},
+		{nome: "fora do turno do profissional", inicio: "09:00", fim: "10:00", data: data, funcionario: &ana, esperado: ErrForaDoTurno},
+		// 01h30 de terça em UTC ainda é segunda, 22h30, em São Paulo
+		{nome: "dia da semana no fuso do salão", inicio: "01:30", fim: "02:00", data: time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC), esperado: ErrForaDoExpediente},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			err := cal.Validar(HorarioNaData(c.data, c.inicio), HorarioNaData(c.data, c.fim), c.funcionario)
+			if !errors.Is(err, c.esperado) {
+				t.Errorf("Validar = %v, esperado %v", err, c.esperado)
+			}
+		})
+	}
+}
+
+func TestMeiaNoite(t *testing.T) {
+	sp := carregarFuso(t, "America/Sao_Paulo")
+	santiago := carregarFuso(t, "America/Santiago")
+
+	casos := []struct {
+		nome     string
+		data     time.Time
+		loc      *time.Location
+		esperado time.Time
+	}{
+		{
+			nome:     "dia comum",
+			data:     time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+			loc:      sp,
+			esperado: time.Date(2025, 3, 10, 0, 0, 0, 0, sp),
+		},
+		{
+			// Em 07/09/2025 Santiago adiantou o relógio à meia-noite: o dia começou à 01h
+			nome:     "dia sem meia-noite",
+			data:     time.Date(2025, 9, 7, 0, 0, 0, 0, time.UTC),
+			loc:      santiago,
+			esperado: time.Date(2025, 9, 7, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			nome:     "data em outro fuso vale pelo calendário",
+			data:     time.Date(2025, 9, 7, 23, 0, 0, 0, sp),
+			loc:      santiago,
+			esperado: time.Date(2025, 9, 7, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			// Em 06/04/2025 Santiago atrasou o relógio: 23h do dia 5 aconteceu duas vezes
+			nome:     "dia seguinte ao de 25 horas",
+			data:     time.Date(2025, 4, 6, 0, 0, 0, 0, time.UTC),
+			loc:      santiago,
+			esperado: time.Date(2025, 4, 6, 0, 0, 0, 0, santiago),
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			got := MeiaNoite(c.data, c.loc)
+			if !got.Equal(c.esperado) {
+				t.Errorf("MeiaNoite = %v, esperado %v", got, c.esperado)
+			}
+			if local := got.In(c.loc); local.Day() != c.data.Day() {
+				t.Errorf("MeiaNoite caiu no dia %d, esperado %d", local.Day(), c.data.Day())
+			}
+		})
+	}
+}
+
+func TestSomarDias(t *testing.T) {
+	santiago := carregarFuso(t, "America/Santiago")
+	sabado := MeiaNoite(time.Date(2025, 9, 6, 0, 0, 0, 0, time.UTC), santiago)
+
+	domingo := SomarDias(sabado, 1)
+	if esperado := time.Date(2025, 9, 7, 1, 0, 0, 0, santiago); !domingo.Equal(esperado) {
+		t.Errorf("SomarDias(sábado, 1) = %v, esperado %v", domingo, esperado)
+	}
+	if DiaDaSemana(domingo) != "domingo" {
+		t.Errorf("DiaDaSemana = %s, esperado domingo", DiaDaSemana(domingo))
+	}
+	if segunda := SomarDias(sabado, 2); !segunda.Equal(time.Date(2025, 9, 8, 0, 0, 0, 0, santiago)) {
+		t.Errorf("SomarDias(sábado, 2) = %v", segunda)
+	}
+}
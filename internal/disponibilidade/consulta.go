@@ -0,0 +1,394 @@
+package disponibilidade
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/models"
+)
+
+var (
+	ErrSemProfissional = errors.New("nenhum profissional realiza o serviço")
+	ErrHorarioOcupado  = errors.New("horário ocupado por outro agendamento")
+	ErrForaDaGrade     = errors.New("horário não está entre os oferecidos para o serviço")
+)
+
+// ConflitoError é o ErrHorarioOcupado causado por um trecho já reservado,
+// que fica disponível para ser mostrado ao cliente.
+type ConflitoError struct {
+	Ocupado models.Agendamento
+}
+
+func (e *ConflitoError) Error() string        { return ErrHorarioOcupado.Error() }
+func (e *ConflitoError) Is(target error) bool { return target == ErrHorarioOcupado }
+
+// Condicoes são a duração e o preço do serviço com um profissional.
+type Condicoes struct {
+	DuracaoMinutos int
+	Preco          float64
+}
+
+// SlotsFuncionario agrupa os horários em que um profissional faz a visita
+// inteira sozinho, com a duração e o preço totais quando feita por ele.
+type SlotsFuncionario struct {
+	FuncionarioID  int      `json:"funcionario_id"`
+	Nome           string   `json:"nome"`
+	DuracaoMinutos int      `json:"duracao_minutos"`
+	Preco          float64  `json:"preco"`
+	Slots          []string `json:"slots"`
+}
+
+// Dia são os horários livres de um dia. Slots reúne os horários em que a visita
+// pode começar com qualquer combinação de profissionais; Funcionarios só vem
+// preenchido quando pedido o agrupamento por profissional.
+type Dia struct {
+	Data         string             `json:"data"`
+	Slots        []string           `json:"slots"`
+	Funcionarios []SlotsFuncionario `json:"funcionarios,omitempty"`
+}
+
+// Etapa é um dos serviços da visita, com os profissionais que podem realizá-lo.
+type Etapa struct {
+	Servico      models.Servico
+	Funcionarios []models.Funcionario // Vazio em salões sem profissionais
+	Condicoes    map[int]Condicoes
+}
+
+// Alocacao é um serviço da visita já encaixado na agenda.
+type Alocacao struct {
+	Servico     models.Servico
+	Funcionario *models.Funcionario // Nulo na agenda única de um salão sem profissionais
+	Inicio, Fim time.Time
+	Preco       float64
+
+	// Trecho ocupado na agenda do profissional, incluindo os buffers
+	BloqueioInicio, BloqueioFim time.Time
+}
+
+// Consulta reúne o que o motor precisa para uma visita (um ou mais serviços
+// em sequência) num período: é carregada uma vez e usada para todos os dias.
+type Consulta struct {
+	Calendario *Calendario
+	Config     models.ConfiguracoesSalao
+
+	// SemFuncionarios indica um salão sem profissionais cadastrados, com uma
+	// única agenda.
+	SemFuncionarios bool
+	Etapas          []Etapa
+
+	// Trechos ocupados nas agendas: um por serviço de cada agendamento ativo
+	// que cruza o período. FuncionarioID e Bloqueio* indicam agenda e trecho.
+	Agendamentos []models.Agendamento
+
+	// Janela de reservas: a visita só pode começar a partir de Primeiro e antes de Limite
+	Primeiro, Limite time.Time
+}
+
+// NovaConsulta monta a consulta, calculando a janela de reservas a partir do
+// momento atual. As etapas e os agendamentos são preenchidos por quem carrega.
+func NovaConsulta(cal *Calendario, config models.ConfiguracoesSalao, janela JanelaReserva, agora time.Time) *Consulta {
+	c := &Consulta{Calendario: cal, Config: config}
+	c.Primeiro, c.Limite = janela.Limites(agora)
+	return c
+}
+
+// Condicoes devolve a duração e o preço da etapa i com o profissional f (ou
+// os valores do próprio serviço, se f for nulo ou não tiver valores próprios).
+func (c *Consulta) Condicoes(i int, f *models.Funcionario) Condicoes {
+	e := c.Etapas[i]
+	if f != nil {
+		if cond, ok := e.Condicoes[f.ID]; ok {
+			return cond
+		}
+	}
+	return Condicoes{DuracaoMinutos: e.Servico.DuracaoMinutos, Preco: e.Servico.Preco}
+}
+
+// DoDia calcula os horários livres da data (meia-noite no fuso do salão).
+// Com porFuncionario, preenche também os horários de cada profissional que
+// consegue fazer a visita inteira sozinho.
+func (c *Consulta) DoDia(data time.Time, porFuncionario bool) Dia {
+	dia := Dia{Data: data.Format("2006-01-02"), Slots: formatar(c.slots(data, nil))}
+	if !porFuncionario || c.SemFuncionarios {
+		return dia
+	}
+
+	dia.Funcionarios = []SlotsFuncionario{}
+	for _, f := range c.Etapas[0].Funcionarios {
+		if !c.realizaTodas(f) {
+			continue
+		}
+		sf := SlotsFuncionario{FuncionarioID: f.ID, Nome: f.Nome, Slots: formatar(c.slots(data, &f))}
+		for i := range c.Etapas {
+			cond := c.Condicoes(i, &f)
+			sf.DuracaoMinutos += cond.DuracaoMinutos
+			sf.Preco += cond.Preco
+		}
+		dia.Funcionarios = append(dia.Funcionarios, sf)
+	}
+	return dia
+}
+
+// Planejar encaixa a visita começando em inicio, escolhendo um profissional
+// para cada serviço (os menos ocupados no dia primeiro). Só encontra um plano
+// se DoDia ofereceria o horário; caso contrário, devolve o motivo mais útil:
+// ErrAntecedenciaMinima, ErrForaDoHorizonte, os erros de
+// Calendario.Validar, ErrSemProfissional, ErrForaDaGrade ou ErrHorarioOcupado.
+func (c *Consulta) Planejar(inicio time.Time) ([]Alocacao, error) {
+	return c.planejar(inicio, nil, c.CargaDoDia(inicio))
+}
+
+// planejar faz a busca de Planejar; so, se informado, é o único profissional aceito.
+func (c *Consulta) planejar(inicio time.Time, so *models.Funcionario, carga map[int]int) ([]Alocacao, error) {
+	if inicio.Before(c.Primeiro) {
+		return nil, ErrAntecedenciaMinima
+	}
+	if !c.Limite.IsZero() && !inicio.Before(c.Limite) {
+		return nil, ErrForaDoHorizonte
+	}
+
+	data := MeiaNoite(inicio.In(c.Calendario.Loc), c.Calendario.Loc)
+	ocupados := agendamentosDoDia(c.Agendamentos, data)
+	motivo := ErrSemProfissional
+	registrar := func(err error) {
+		if prioridade(err) > prioridade(motivo) {
+			motivo = err
+		}
+	}
+
+	// Cada serviço começa quando o anterior termina; tenta os profissionais
+	// possíveis de cada etapa até a visita inteira caber
+	plano := make([]Alocacao, 0, len(c.Etapas))
+	var encaixar func(i int, em time.Time) bool
+	encaixar = func(i int, em time.Time) bool {
+		if i == len(c.Etapas) {
+			aplicarBuffers(plano)
+			// Com os buffers, a preparação e a limpeza também precisam caber no expediente
+			for _, a := range plano {
+				if err := c.Calendario.Validar(a.BloqueioInicio, a.BloqueioFim, a.Funcionario); err != nil {
+					registrar(err)
+					return false
+				}
+			}
+			if err := conflitos(plano, ocupados); err != nil {
+				registrar(err)
+				return false
+			}
+			return true
+		}
+		for _, f := range c.opcoes(i, so, carga) {
+			cond := c.Condicoes(i, f)
+			fim := em.Add(time.Duration(cond.DuracaoMinutos) * time.Minute)
+			if err := c.Calendario.Validar(em, fim, f); err != nil {
+				registrar(err)
+				continue
+			}
+			if i == 0 && !contem(c.candidatos(data, f), em) {
+				registrar(ErrForaDaGrade)
+				continue
+			}
+			plano = append(plano[:i], Alocacao{Servico: c.Etapas[i].Servico, Funcionario: f, Inicio: em, Fim: fim, Preco: cond.Preco})
+			if encaixar(i+1, fim) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !encaixar(0, inicio) {
+		return nil, motivo
+	}
+	return plano, nil
+}
+
+// CargaDoDia conta, por profissional, os serviços agendados que começam no
+// mesmo dia (no fuso do salão) que inicio.
+func (c *Consulta) CargaDoDia(inicio time.Time) map[int]int {
+	data := MeiaNoite(inicio.In(c.Calendario.Loc), c.Calendario.Loc)
+	fimDia := SomarDias(data, 1)
+	carga := make(map[int]int)
+	for _, a := range c.Agendamentos {
+		if a.FuncionarioID != nil && !a.DataHoraInicio.Before(data) && a.DataHoraInicio.Before(fimDia) {
+			carga[*a.FuncionarioID]++
+		}
+	}
+	return carga
+}
+
+// slots devolve, em ordem, os horários da data em que a visita pode começar.
+func (c *Consulta) slots(data time.Time, so *models.Funcionario) []time.Time {
+	carga := c.CargaDoDia(data)
+	vistos := make(map[int64]bool)
+	var slots []time.Time
+	for _, f := range c.opcoes(0, so, carga) {
+		for _, inicio := range c.candidatos(data, f) {
+			if vistos[inicio.Unix()] {
+				continue
+			}
+			vistos[inicio.Unix()] = true
+			if _, err := c.planejar(inicio, so, carga); err == nil {
+				slots = append(slots, inicio)
+			}
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Before(slots[j]) })
+	return slots
+}
+
+// opcoes devolve quem pode fazer a etapa i, do menos ao mais ocupado: os
+// profissionais (só so, se informado) ou, num salão sem profissionais, a agenda única (nil).
+func (c *Consulta) opcoes(i int, so *models.Funcionario, carga map[int]int) []*models.Funcionario {
+	if c.SemFuncionarios {
+		return []*models.Funcionario{nil}
+	}
+	var opcoes []*models.Funcionario
+	for j := range c.Etapas[i].Funcionarios {
+		f := &c.Etapas[i].Funcionarios[j]
+		if so == nil || f.ID == so.ID {
+			opcoes = append(opcoes, f)
+		}
+	}
+	sort.SliceStable(opcoes, func(a, b int) bool { return carga[opcoes[a].ID] < carga[opcoes[b].ID] })
+	return opcoes
+}
+
+// candidatos devolve os horários em que a visita pode tentar começar com f
+// na primeira etapa, seguindo a grade do primeiro serviço.
+func (c *Consulta) candidatos(data time.Time, f *models.Funcionario) []time.Time {
+	horario := c.Calendario.DiaDoSalao(data)
+	if f != nil {
+		horario = c.Calendario.DiaDoFuncionario(data, *f)
+	}
+	if horario == nil {
+		return nil
+	}
+
+	// A duração estimada é a da visita inteira com f, para o modo que evita lacunas
+	ultima := len(c.Etapas) - 1
+	regra := RegraDoServico(c.Config, c.Etapas[0].Servico, c.Condicoes(0, f).DuracaoMinutos)
+	for i := 1; i <= ultima; i++ {
+		regra.Duracao += time.Duration(c.Condicoes(i, f).DuracaoMinutos) * time.Minute
+	}
+	regra.BufferDepois = time.Duration(c.Etapas[ultima].Servico.BufferDepoisMinutos) * time.Minute
+	regra.Primeiro, regra.Limite = c.Primeiro, c.Limite
+	return Candidatos(data, horario, regra, agendamentosDaAgenda(agendamentosDoDia(c.Agendamentos, data), f))
+}
+
+// realizaTodas diz se o profissional pode fazer todos os serviços da visita.
+func (c *Consulta) realizaTodas(f models.Funcionario) bool {
+	for _, e := range c.Etapas {
+		achou := false
+		for _, g := range e.Funcionarios {
+			achou = achou || g.ID == f.ID
+		}
+		if !achou {
+			return false
+		}
+	}
+	return true
+}
+
+// aplicarBuffers calcula o trecho ocupado por cada serviço do plano. A
+// preparação só conta quando o profissional começa a atender (início da visita
+// ou troca de profissional), e a limpeza só quando ele termina.
+func aplicarBuffers(plano []Alocacao) {
+	for i := range plano {
+		a := &plano[i]
+		a.BloqueioInicio, a.BloqueioFim = a.Inicio, a.Fim
+		if i == 0 || !mesmaAgenda(plano[i-1].Funcionario, a.Funcionario) {
+			a.BloqueioInicio = a.Inicio.Add(-time.Duration(a.Servico.BufferAntesMinutos) * time.Minute)
+		}
+		if i == len(plano)-1 || !mesmaAgenda(plano[i+1].Funcionario, a.Funcionario) {
+			a.BloqueioFim = a.Fim.Add(time.Duration(a.Servico.BufferDepoisMinutos) * time.Minute)
+		}
+	}
+}
+
+// conflitos confere o plano contra os trechos já ocupados (devolvendo um
+// *ConflitoError) e contra ele mesmo (um profissional que volta a atender na
+// mesma visita).
+func conflitos(plano []Alocacao, ocupados []models.Agendamento) error {
+	for i, a := range plano {
+		for _, o := range agendamentosDaAgenda(ocupados, a.Funcionario) {
+			if sobrepoe(a.BloqueioInicio, a.BloqueioFim, o.BloqueioInicio, o.BloqueioFim) {
+				return &ConflitoError{Ocupado: o}
+			}
+		}
+		for _, b := range plano[i+1:] {
+			if mesmaAgenda(a.Funcionario, b.Funcionario) && sobrepoe(a.BloqueioInicio, a.BloqueioFim, b.BloqueioInicio, b.BloqueioFim) {
+				return ErrHorarioOcupado
+			}
+		}
+	}
+	return nil
+}
+
+// prioridade ordena os motivos de recusa pela ordem em que a busca os
+// confere: quando nenhuma combinação serve, o motivo de quem chegou mais
+// longe é o mais útil para o cliente.
+func prioridade(err error) int {
+	switch {
+	case errors.Is(err, ErrSemProfissional):
+		return 0
+	case errors.Is(err, ErrAntecedenciaMinima), errors.Is(err, ErrForaDoHorizonte):
+		return 1
+	case errors.Is(err, ErrForaDoTurno):
+		return 3
+	case errors.Is(err, ErrForaDaGrade):
+		return 4
+	case errors.Is(err, ErrHorarioOcupado):
+		return 5
+	}
+	return 2 // Salão fechado, fora do expediente ou em pausa
+}
+
+// agendamentosDoDia filtra os agendamentos cujo bloqueio cruza a data (meia-noite local).
+// Os limites do dia seguem o fuso da data, inclusive nos dias de 23h ou 25h do horário de verão.
+func agendamentosDoDia(agendamentos []models.Agendamento, data time.Time) []models.Agendamento {
+	fimDia := SomarDias(data, 1)
+	var filtrados []models.Agendamento
+	for _, a := range agendamentos {
+		if sobrepoe(data, fimDia, a.BloqueioInicio, a.BloqueioFim) {
+			filtrados = append(filtrados, a)
+		}
+	}
+	return filtrados
+}
+
+// agendamentosDaAgenda filtra os agendamentos que ocupam a agenda do profissional.
+// Com f nulo, devolve os agendamentos sem profissional atribuído.
+func agendamentosDaAgenda(agendamentos []models.Agendamento, f *models.Funcionario) []models.Agendamento {
+	var filtrados []models.Agendamento
+	for _, a := range agendamentos {
+		if (a.FuncionarioID == nil && f == nil) || (a.FuncionarioID != nil && f != nil && *a.FuncionarioID == f.ID) {
+			filtrados = append(filtrados, a)
+		}
+	}
+	return filtrados
+}
+
+func mesmaAgenda(a, b *models.Funcionario) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.ID == b.ID
+}
+
+func contem(horarios []time.Time, t time.Time) bool {
+	for _, h := range horarios {
+		if h.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatar converte os horários para "HH:MM", sempre devolvendo uma lista (nunca nil).
+func formatar(slots []time.Time) []string {
+	formatados := make([]string, 0, len(slots))
+	for _, s := range slots {
+		formatados = append(formatados, s.Format("15:04"))
+	}
+	return formatados
+}
@@ -0,0 +1,375 @@
+package disponibilidade
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/models"
+)
+
+// consultaDeTeste monta uma consulta de um serviço num salão sem profissionais,
+// aberto das 09h às 12h às segundas, com horários a cada 30 minutos.
+func consultaDeTeste(data time.Time, servico models.Servico) *Consulta {
+	semanal := models.HorarioSemanal{"segunda": {Inicio: "09:00", Fim: "12:00"}}
+	return &Consulta{
+		Calendario:      NovoCalendario(semanal, data.Location()),
+		Config:          models.ConfiguracoesSalao{IntervaloSlotsMinutos: 30},
+		SemFuncionarios: true,
+		Etapas:          []Etapa{{Servico: servico}},
+	}
+}
+
+func TestConsultaDoDia(t *testing.T) {
+	data := segunda(t)
+	corte := models.Servico{ID: 1, Nome: "Corte", DuracaoMinutos: 60, Preco: 50}
+
+	casos := []struct {
+		nome     string
+		ajustar  func(c *Consulta)
+		esperado []string
+	}{
+		{
+			nome:     "último horário termina no fechamento",
+			esperado: []string{"09:00", "09:30", "10:00", "10:30", "11:00"},
+		},
+		{
+			nome: "pausa corta os horários que a invadem",
+			ajustar: func(c *Consulta) {
+				c.Calendario.Semanal["segunda"].Pausas = []models.Pausa{{Inicio: "10:00", Fim: "10:30"}}
+			},
+			esperado: []string{"09:00", "10:30", "11:00"},
+		},
+		{
+			nome: "agendamento encostado não bloqueia os vizinhos",
+			ajustar: func(c *Consulta) {
+				c.Agendamentos = []models.Agendamento{ocupado(data, 1, nil, "10:00", "11:00")}
+			},
+			esperado: []string{"09:00", "11:00"},
+		},
+		{
+			nome: "agendamento sobreposto bloqueia todos os horários que o cruzam",
+			ajustar: func(c *Consulta) {
+				c.Agendamentos = []models.Agendamento{ocupado(data, 1, nil, "09:45", "10:15")}
+			},
+			esperado: []string{"10:30", "11:00"},
+		},
+		{
+			nome: "limpeza não passa do fechamento",
+			ajustar: func(c *Consulta) {
+				c.Etapas[0].Servico.BufferDepoisMinutos = 15
+			},
+			esperado: []string{"09:00", "09:30", "10:00", "10:30"},
+		},
+		{
+			nome: "limpeza não invade a pausa",
+			ajustar: func(c *Consulta) {
+				c.Etapas[0].Servico.BufferDepoisMinutos = 15
+				c.Calendario.Semanal["segunda"].Pausas = []models.Pausa{{Inicio: "11:00", Fim: "11:30"}}
+			},
+			esperado: []string{"09:00", "09:30"},
+		},
+		{
+			nome: "preparação não começa antes da abertura",
+			ajustar: func(c *Consulta) {
+				c.Etapas[0].Servico.BufferAntesMinutos = 10
+			},
+			esperado: []string{"09:30", "10:00", "10:30", "11:00"},
+		},
+		{
+			nome: "lacunas mínimas com limpeza encostam no fechamento",
+			ajustar: func(c *Consulta) {
+				c.Config.MinimizarLacunas = true
+				c.Etapas[0].Servico.BufferDepoisMinutos = 15
+			},
+			esperado: []string{"09:00", "10:45"},
+		},
+		{
+			nome: "antecedência mínima",
+			ajustar: func(c *Consulta) {
+				c.Primeiro = HorarioNaData(data, "09:45")
+			},
+			esperado: []string{"10:00", "10:30", "11:00"},
+		},
+		{
+			nome: "salão fechado por exceção",
+			ajustar: func(c *Consulta) {
+				c.Calendario.AdicionarExcecao(models.ExcecaoHorario{Data: "2025-03-10", Fechado: true})
+			},
+			esperado: []string{},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			consulta := consultaDeTeste(data, corte)
+			if c.ajustar != nil {
+				c.ajustar(consulta)
+			}
+			dia := consulta.DoDia(data, false)
+			if dia.Data != "2025-03-10" {
+				t.Errorf("Data = %s, esperado 2025-03-10", dia.Data)
+			}
+			if !slices.Equal(dia.Slots, c.esperado) {
+				t.Errorf("Slots = %v, esperado %v", dia.Slots, c.esperado)
+			}
+			if dia.Funcionarios != nil {
+				t.Errorf("Funcionarios = %v, esperado nenhum", dia.Funcionarios)
+			}
+		})
+	}
+}
+
+func TestConsultaDoDiaPorFuncionario(t *testing.T) {
+	data := segunda(t)
+	ana := models.Funcionario{ID: 1, Nome: "Ana"}
+	bia := models.Funcionario{ID: 2, Nome: "Bia"}
+	c := consultaDeTeste(data, models.Servico{ID: 1, DuracaoMinutos: 60, Preco: 50})
+	c.SemFuncionarios = false
+	c.Etapas[0].Funcionarios = []models.Funcionario{ana, bia}
+	c.Etapas[0].Condicoes = map[int]Condicoes{bia.ID: {DuracaoMinutos: 30, Preco: 40}}
+	c.Agendamentos = []models.Agendamento{ocupado(data, 1, &ana.ID, "09:00", "11:00")}
+
+	dia := c.DoDia(data, true)
+
+	// Com a Bia livre, a visita pode começar em qualquer horário que caiba nela
+	if esperado := []string{"09:00", "09:30", "10:00", "10:30", "11:00", "11:30"}; !slices.Equal(dia.Slots, esperado) {
+		t.Errorf("Slots = %v, esperado %v", dia.Slots, esperado)
+	}
+	esperados := []SlotsFuncionario{
+		{FuncionarioID: ana.ID, Nome: "Ana", DuracaoMinutos: 60, Preco: 50, Slots: []string{"11:00"}},
+		{FuncionarioID: bia.ID, Nome: "Bia", DuracaoMinutos: 30, Preco: 40, Slots: []string{"09:00", "09:30", "10:00", "10:30", "11:00", "11:30"}},
+	}
+	if len(dia.Funcionarios) != len(esperados) {
+		t.Fatalf("Funcionarios = %v, esperado %v", dia.Funcionarios, esperados)
+	}
+	for i, e := range esperados {
+		f := dia.Funcionarios[i]
+		if f.FuncionarioID != e.FuncionarioID || f.Nome != e.Nome || f.DuracaoMinutos != e.DuracaoMinutos || f.Preco != e.Preco || !slices.Equal(f.Slots, e.Slots) {
+			t.Errorf("Funcionarios[%d] = %+v, esperado %+v", i, f, e)
+		}
+	}
+}
+
+// Em 07/09/2025 Santiago adiantou o relógio à meia-noite; o domingo começou à
+// 01h e precisa usar o expediente de domingo, não o de sábado.
+func TestConsultaDoDiaSemMeiaNoite(t *testing.T) {
+	santiago := carregarFuso(t, "America/Santiago")
+	semanal := models.HorarioSemanal{
+		"sabado":  {Inicio: "09:00", Fim: "10:00"},
+		"domingo": {Inicio: "10:00", Fim: "12:00"},
+	}
+	c := &Consulta{
+		Calendario:      NovoCalendario(semanal, santiago),
+		Config:          models.ConfiguracoesSalao{IntervaloSlotsMinutos: 30},
+		SemFuncionarios: true,
+		Etapas:          []Etapa{{Servico: models.Servico{ID: 1, DuracaoMinutos: 60}}},
+	}
+
+	dia := c.DoDia(MeiaNoite(time.Date(2025, 9, 7, 0, 0, 0, 0, time.UTC), santiago), false)
+	if dia.Data != "2025-09-07" {
+		t.Errorf("Data = %s, esperado 2025-09-07", dia.Data)
+	}
+	if esperado := []string{"10:00", "10:30", "11:00"}; !slices.Equal(dia.Slots, esperado) {
+		t.Errorf("Slots = %v, esperado %v", dia.Slots, esperado)
+	}
+	if carga := c.CargaDoDia(time.Date(2025, 9, 7, 10, 0, 0, 0, santiago)); len(carga) != 0 {
+		t.Errorf("CargaDoDia = %v, esperado vazia", carga)
+	}
+}
+
+func TestConsultaPlanejar(t *testing.T) {
+	data := segunda(t)
+	ana := models.Funcionario{ID: 1, Nome: "Ana"}
+	bia := models.Funcionario{ID: 2, Nome: "Bia", Horarios: models.HorarioSemanal{"segunda": {Inicio: "10:00", Fim: "12:00"}}}
+	corte := models.Servico{ID: 1, Nome: "Corte", DuracaoMinutos: 60, Preco: 50}
+	escova := models.Servico{ID: 2, Nome: "Escova", DuracaoMinutos: 30, Preco: 30}
+
+	// Corte com a Ana ou a Bia, seguido de escova, que só a Bia faz
+	combo := func(c *Consulta) {
+		c.SemFuncionarios = false
+		c.Etapas = []Etapa{
+			{Servico: corte, Funcionarios: []models.Funcionario{ana, bia}},
+			{Servico: escova, Funcionarios: []models.Funcionario{bia}},
+		}
+	}
+
+	type alocado struct {
+		servico, funcionario int
+		inicio, fim          string
+	}
+	casos := []struct {
+		nome     string
+		ajustar  func(c *Consulta)
+		inicio   string
+		erro     error
+		esperado []alocado
+	}{
+		{
+			nome:     "serviço único",
+			inicio:   "10:00",
+			esperado: []alocado{{servico: 1, inicio: "10:00", fim: "11:00"}},
+		},
+		{
+			nome:   "fora da grade",
+			inicio: "09:10",
+			erro:   ErrForaDaGrade,
+		},
+		{
+			nome:   "passa do fechamento",
+			inicio: "11:30",
+			erro:   ErrForaDoExpediente,
+		},
+		{
+			nome: "limpeza passa do fechamento",
+			ajustar: func(c *Consulta) {
+				c.Etapas[0].Servico.BufferDepoisMinutos = 15
+			},
+			inicio: "11:00",
+			erro:   ErrForaDoExpediente,
+		},
+		{
+			nome: "invade a pausa",
+			ajustar: func(c *Consulta) {
+				c.Calendario.Semanal["segunda"].Pausas = []models.Pausa{{Inicio: "10:00", Fim: "10:30"}}
+			},
+			inicio: "09:30",
+			erro:   ErrPausa,
+		},
+		{
+			nome: "limpeza invade a pausa",
+			ajustar: func(c *Consulta) {
+				c.Etapas[0].Servico.BufferDepoisMinutos = 15
+				c.Calendario.Semanal["segunda"].Pausas = []models.Pausa{{Inicio: "10:00", Fim: "10:30"}}
+			},
+			inicio: "09:00",
+			erro:   ErrPausa,
+		},
+		{
+			nome: "sobrepõe outro agendamento",
+			ajustar: func(c *Consulta) {
+				c.Agendamentos = []models.Agendamento{ocupado(data, 7, nil, "10:30", "11:00")}
+			},
+			inicio: "10:00",
+			erro:   ErrHorarioOcupado,
+		},
+		{
+			nome:   "salão fechado",
+			inicio: "",
+			erro:   ErrSalaoFechado,
+		},
+		{
+			nome: "antes da antecedência mínima",
+			ajustar: func(c *Consulta) {
+				c.Primeiro = HorarioNaData(data, "10:30")
+			},
+			inicio: "10:00",
+			erro:   ErrAntecedenciaMinima,
+		},
+		{
+			nome: "depois do horizonte",
+			ajustar: func(c *Consulta) {
+				c.Limite = data
+			},
+			inicio: "10:00",
+			erro:   ErrForaDoHorizonte,
+		},
+		{
+			nome:    "combo troca de profissional entre os serviços",
+			ajustar: combo,
+			inicio:  "09:00",
+			esperado: []alocado{
+				{servico: 1, funcionario: ana.ID, inicio: "09:00", fim: "10:00"},
+				{servico: 2, funcionario: bia.ID, inicio: "10:00", fim: "10:30"},
+			},
+		},
+		{
+			nome: "combo prefere quem está menos ocupado",
+			ajustar: func(c *Consulta) {
+				combo(c)
+				c.Agendamentos = []models.Agendamento{ocupado(data, 7, &ana.ID, "09:00", "09:30")}
+			},
+			inicio: "10:00",
+			esperado: []alocado{
+				{servico: 1, funcionario: bia.ID, inicio: "10:00", fim: "11:00"},
+				{servico: 2, funcionario: bia.ID, inicio: "11:00", fim: "11:30"},
+			},
+		},
+		{
+			nome: "fora do turno do profissional",
+			ajustar: func(c *Consulta) {
+				combo(c)
+				c.Etapas[0].Funcionarios = []models.Funcionario{bia}
+			},
+			inicio: "09:00",
+			erro:   ErrForaDoTurno,
+		},
+		{
+			nome: "combo com a segunda etapa ocupada",
+			ajustar: func(c *Consulta) {
+				combo(c)
+				c.Agendamentos = []models.Agendamento{ocupado(data, 9, &bia.ID, "10:00", "10:30")}
+			},
+			inicio: "09:00",
+			erro:   ErrHorarioOcupado,
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			consulta := consultaDeTeste(data, corte)
+			if c.ajustar != nil {
+				c.ajustar(consulta)
+			}
+			inicio := data.AddDate(0, 0, -1).Add(10 * time.Hour) // Domingo, sem expediente
+			if c.inicio != "" {
+				inicio = HorarioNaData(data, c.inicio)
+			}
+
+			plano, err := consulta.Planejar(inicio)
+			if !errors.Is(err, c.erro) {
+				t.Fatalf("Planejar(%s) = %v, esperado %v", c.inicio, err, c.erro)
+			}
+			if len(plano) != len(c.esperado) {
+				t.Fatalf("plano = %+v, esperado %+v", plano, c.esperado)
+			}
+			for i, e := range c.esperado {
+				a := plano[i]
+				funcionario := 0
+				if a.Funcionario != nil {
+					funcionario = a.Funcionario.ID
+				}
+				if a.Servico.ID != e.servico || funcionario != e.funcionario ||
+					!a.Inicio.Equal(HorarioNaData(data, e.inicio)) || !a.Fim.Equal(HorarioNaData(data, e.fim)) {
+					t.Errorf("plano[%d] = serviço %d, profissional %d, %s-%s; esperado %+v", i,
+						a.Servico.ID, funcionario, a.Inicio.Format("15:04"), a.Fim.Format("15:04"), e)
+				}
+			}
+		})
+	}
+}
+
+func TestConsultaPlanejarConflito(t *testing.T) {
+	data := segunda(t)
+	c := consultaDeTeste(data, models.Servico{ID: 1, DuracaoMinutos: 60, BufferDepoisMinutos: 15})
+	c.Agendamentos = []models.Agendamento{ocupado(data, 42, nil, "10:00", "10:30")}
+
+	// O atendimento das 09h termina às 10h, mas a limpeza esbarra no agendamento 42
+	_, err := c.Planejar(HorarioNaData(data, "09:00"))
+	var conflito *ConflitoError
+	if !errors.As(err, &conflito) {
+		t.Fatalf("Planejar = %v, esperado *ConflitoError", err)
+	}
+	if conflito.Ocupado.ID != 42 {
+		t.Errorf("Ocupado.ID = %d, esperado 42", conflito.Ocupado.ID)
+	}
+
+	plano, err := c.Planejar(HorarioNaData(data, "10:30"))
+	if err != nil {
+		t.Fatalf("Planejar(10:30) = %v", err)
+	}
+	if fim := HorarioNaData(data, "11:45"); !plano[0].BloqueioFim.Equal(fim) {
+		t.Errorf("BloqueioFim = %v, esperado %v", plano[0].BloqueioFim, fim)
+	}
+}
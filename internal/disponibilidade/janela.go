@@ -0,0 +1,40 @@
+package disponibilidade
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrAntecedenciaMinima = errors.New("horário com menos antecedência do que o salão exige")
+	ErrForaDoHorizonte    = errors.New("horário além do horizonte de reservas do salão")
+)
+
+// JanelaReserva limita quando um cliente pode marcar: com pelo menos
+// AntecedenciaMinima de antecedência e até HorizonteDias dias corridos à frente,
+// contando o dia de hoje no fuso do salão (Loc) como dia zero.
+type JanelaReserva struct {
+	AntecedenciaMinima time.Duration
+	HorizonteDias      int
+	Loc                *time.Location
+}
+
+// Limites devolve o primeiro instante em que um atendimento pode começar e o
+// fim (exclusivo) do último dia dentro do horizonte.
+func (j JanelaReserva) Limites(agora time.Time) (primeiro, limite time.Time) {
+	hoje := MeiaNoite(agora.In(j.Loc), j.Loc)
+	return agora.Add(j.AntecedenciaMinima), SomarDias(hoje, j.HorizonteDias+1)
+}
+
+// Verificar diz se um atendimento começando em inicio respeita a janela.
+// Devolve ErrAntecedenciaMinima ou ErrForaDoHorizonte quando não respeita.
+func (j JanelaReserva) Verificar(inicio, agora time.Time) error {
+	primeiro, limite := j.Limites(agora)
+	if inicio.Before(primeiro) {
+		return ErrAntecedenciaMinima
+	}
+	if !inicio.Before(limite) {
+		return ErrForaDoHorizonte
+	}
+	return nil
+}
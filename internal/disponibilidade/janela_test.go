@@ -0,0 +1,207 @@
+package disponibilidade
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func carregarFuso(t *testing.T, nome string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(nome)
+	if err != nil {
+		t.Fatalf("fuso %s indisponível: %v", nome, err)
+	}
+	return loc
+}
+
+func TestJanelaReservaVerificar(t *testing.T) {
+	sp := carregarFuso(t, "America/Sao_Paulo")
+	agora := time.Date(2025, 3, 10, 14, 0, 0, 0, sp)
+
+	casos := []struct {
+		nome   string
+		janela JanelaReserva
+		inicio time.Time
+		agora  time.Time
+		erro   error
+	}{
+		{
+			nome:   "exatamente na antecedência mínima",
+			janela: JanelaReserva{AntecedenciaMinima: 2 * time.Hour, HorizonteDias: 30, Loc: sp},
+			inicio: agora.Add(2 * time.Hour),
+			agora:  agora,
+		},
+		{
+			nome:   "um minuto antes da antecedência mínima",
+			janela: JanelaReserva{AntecedenciaMinima: 2 * time.Hour, HorizonteDias: 30, Loc: sp},
+			inicio: agora.Add(2*time.Hour - time.Minute),
+			agora:  agora,
+			erro:   ErrAntecedenciaMinima,
+		},
+		{
+			nome:   "sem antecedência recusa horário que já passou",
+			janela: JanelaReserva{HorizonteDias: 30, Loc: sp},
+			inicio: agora.Add(-time.Minute),
+			agora:  agora,
+			erro:   ErrAntecedenciaMinima,
+		},
+		{
+			nome:   "sem antecedência aceita o próprio instante",
+			janela: JanelaReserva{HorizonteDias: 30, Loc: sp},
+			inicio: agora,
+			agora:  agora,
+		},
+		{
+			nome:   "último minuto do último dia do horizonte",
+			janela: JanelaReserva{HorizonteDias: 7, Loc: sp},
+			inicio: time.Date(2025, 3, 17, 23, 59, 0, 0, sp),
+			agora:  agora,
+		},
+		{
+			nome:   "meia-noite depois do horizonte",
+			janela: JanelaReserva{HorizonteDias: 7, Loc: sp},
+			inicio: time.Date(2025, 3, 18, 0, 0, 0, 0, sp),
+			agora:  agora,
+			erro:   ErrForaDoHorizonte,
+		},
+		{
+			nome:   "horizonte zero permite só o dia de hoje",
+			janela: JanelaReserva{HorizonteDias: 0, Loc: sp},
+			inicio: time.Date(2025, 3, 10, 23, 0, 0, 0, sp),
+			agora:  agora,
+		},
+		{
+			nome:   "horizonte zero recusa amanhã",
+			janela: JanelaReserva{HorizonteDias: 0, Loc: sp},
+			inicio: time.Date(2025, 3, 11, 9, 0, 0, 0, sp),
+			agora:  agora,
+			erro:   ErrForaDoHorizonte,
+		},
+		{
+			// 23h30 em UTC ainda é dia 10 em São Paulo: o horizonte conta a partir do dia do salão
+			nome:   "dia zero no fuso do salão e não do servidor",
+			janela: JanelaReserva{HorizonteDias: 0, Loc: sp},
+			inicio: time.Date(2025, 3, 10, 23, 30, 0, 0, sp),
+			agora:  time.Date(2025, 3, 11, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			// Horário de verão de 2018: à meia-noite de 04/11 o relógio pulou para 01h
+			nome:   "antecedência conta horas reais no início do horário de verão",
+			janela: JanelaReserva{AntecedenciaMinima: 2 * time.Hour, HorizonteDias: 30, Loc: sp},
+			inicio: time.Date(2018, 11, 4, 1, 30, 0, 0, sp),
+			agora:  time.Date(2018, 11, 3, 23, 0, 0, 0, sp),
+			erro:   ErrAntecedenciaMinima,
+		},
+		{
+			nome:   "duas horas reais depois no início do horário de verão",
+			janela: JanelaReserva{AntecedenciaMinima: 2 * time.Hour, HorizonteDias: 30, Loc: sp},
+			inicio: time.Date(2018, 11, 4, 2, 0, 0, 0, sp),
+			agora:  time.Date(2018, 11, 3, 23, 0, 0, 0, sp),
+		},
+		{
+			nome:   "horizonte termina no dia que começa à 01h",
+			janela: JanelaReserva{HorizonteDias: 2, Loc: sp},
+			inicio: time.Date(2018, 11, 4, 1, 0, 0, 0, sp),
+			agora:  time.Date(2018, 11, 1, 10, 0, 0, 0, sp),
+			erro:   ErrForaDoHorizonte,
+		},
+		{
+			nome:   "véspera do dia sem meia-noite ainda no horizonte",
+			janela: JanelaReserva{HorizonteDias: 2, Loc: sp},
+			inicio: time.Date(2018, 11, 3, 23, 30, 0, 0, sp),
+			agora:  time.Date(2018, 11, 1, 10, 0, 0, 0, sp),
+		},
+		{
+			// Fim do horário de verão de 2019: 16/02 teve 25 horas e 23h30 aconteceu
+			// duas vezes; a segunda (02h30 UTC) ainda é dia 16 e está no horizonte
+			nome:   "horizonte conta dias corridos no dia de 25 horas",
+			janela: JanelaReserva{HorizonteDias: 1, Loc: sp},
+			inicio: time.Date(2019, 2, 17, 2, 30, 0, 0, time.UTC),
+			agora:  time.Date(2019, 2, 15, 12, 0, 0, 0, sp),
+		},
+		{
+			nome:   "meia-noite depois do dia de 25 horas",
+			janela: JanelaReserva{HorizonteDias: 1, Loc: sp},
+			inicio: time.Date(2019, 2, 17, 0, 0, 0, 0, sp),
+			agora:  time.Date(2019, 2, 15, 12, 0, 0, 0, sp),
+			erro:   ErrForaDoHorizonte,
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			err := c.janela.Verificar(c.inicio, c.agora)
+			if !errors.Is(err, c.erro) {
+				t.Errorf("Verificar(%v) = %v, esperado %v", c.inicio, err, c.erro)
+			}
+		})
+	}
+}
+
+func TestJanelaReservaLimites(t *testing.T) {
+	sp := carregarFuso(t, "America/Sao_Paulo")
+	ny := carregarFuso(t, "America/New_York")
+	santiago := carregarFuso(t, "America/Santiago")
+
+	casos := []struct {
+		nome     string
+		janela   JanelaReserva
+		agora    time.Time
+		primeiro time.Time
+		limite   time.Time
+	}{
+		{
+			nome:     "dia comum",
+			janela:   JanelaReserva{AntecedenciaMinima: 90 * time.Minute, HorizonteDias: 7, Loc: sp},
+			agora:    time.Date(2025, 3, 10, 14, 0, 0, 0, sp),
+			primeiro: time.Date(2025, 3, 10, 15, 30, 0, 0, sp),
+			limite:   time.Date(2025, 3, 18, 0, 0, 0, 0, sp),
+		},
+		{
+			// Em 09/03/2025 Nova York adiantou o relógio às 02h: o limite continua
+			// sendo a meia-noite local, não um múltiplo de 24 horas
+			nome:     "horizonte atravessando o início do horário de verão",
+			janela:   JanelaReserva{HorizonteDias: 3, Loc: ny},
+			agora:    time.Date(2025, 3, 7, 20, 0, 0, 0, ny),
+			primeiro: time.Date(2025, 3, 7, 20, 0, 0, 0, ny),
+			limite:   time.Date(2025, 3, 11, 0, 0, 0, 0, ny),
+		},
+		{
+			nome:     "antecedência atravessando o início do horário de verão",
+			janela:   JanelaReserva{AntecedenciaMinima: 3 * time.Hour, HorizonteDias: 1, Loc: ny},
+			agora:    time.Date(2025, 3, 9, 0, 30, 0, 0, ny),
+			primeiro: time.Date(2025, 3, 9, 4, 30, 0, 0, ny),
+			limite:   time.Date(2025, 3, 11, 0, 0, 0, 0, ny),
+		},
+		{
+			// Em 02/11/2025 Nova York atrasou o relógio às 02h
+			nome:     "antecedência atravessando o fim do horário de verão",
+			janela:   JanelaReserva{AntecedenciaMinima: 3 * time.Hour, HorizonteDias: 0, Loc: ny},
+			agora:    time.Date(2025, 11, 2, 0, 30, 0, 0, ny),
+			primeiro: time.Date(2025, 11, 2, 2, 30, 0, 0, ny),
+			limite:   time.Date(2025, 11, 3, 0, 0, 0, 0, ny),
+		},
+		{
+			// Em 07/09/2025 Santiago adiantou o relógio à meia-noite: o sábado
+			// termina quando o domingo começa, à 01h
+			nome:     "horizonte termina no dia sem meia-noite",
+			janela:   JanelaReserva{HorizonteDias: 0, Loc: santiago},
+			agora:    time.Date(2025, 9, 6, 10, 0, 0, 0, santiago),
+			primeiro: time.Date(2025, 9, 6, 10, 0, 0, 0, santiago),
+			limite:   time.Date(2025, 9, 7, 1, 0, 0, 0, santiago),
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			primeiro, limite := c.janela.Limites(c.agora)
+			if !primeiro.Equal(c.primeiro) {
+				t.Errorf("primeiro = %v, esperado %v", primeiro, c.primeiro)
+			}
+			if !limite.Equal(c.limite) {
+				t.Errorf("limite = %v, esperado %v", limite, c.limite)
+			}
+		})
+	}
+}
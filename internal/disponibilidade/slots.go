@@ -0,0 +1,89 @@
+package disponibilidade
+
+import (
+	"sort"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/models"
+)
+
+// Regra reúne os parâmetros usados para gerar os horários de um serviço.
+type Regra struct {
+	Duracao          time.Duration // Duração cobrada do serviço
+	Intervalo        time.Duration // Distância entre horários oferecidos
+	BufferAntes      time.Duration // Preparação antes do atendimento
+	BufferDepois     time.Duration // Limpeza depois do atendimento
+	MinimizarLacunas bool
+
+	// Janela de reservas: o início precisa estar em [Primeiro, Limite); zero = sem limite
+	Primeiro, Limite time.Time
+}
+
+// RegraDoServico combina as configurações do salão com as do serviço. A duração
+// é a do profissional quando ele tem uma própria.
+func RegraDoServico(config models.ConfiguracoesSalao, servico models.Servico, duracaoMinutos int) Regra {
+	intervalo := config.IntervaloSlotsMinutos
+	if servico.IntervaloSlotsMinutos != nil {
+		intervalo = *servico.IntervaloSlotsMinutos
+	}
+	if intervalo <= 0 {
+		intervalo = 15
+	}
+	return Regra{
+		Duracao:          time.Duration(duracaoMinutos) * time.Minute,
+		Intervalo:        time.Duration(intervalo) * time.Minute,
+		BufferAntes:      time.Duration(servico.BufferAntesMinutos) * time.Minute,
+		BufferDepois:     time.Duration(servico.BufferDepoisMinutos) * time.Minute,
+		MinimizarLacunas: config.MinimizarLacunas,
+	}
+}
+
+// Candidatos devolve os horários em que se tenta começar um atendimento na
+// data, dentro do horário informado; quais deles estão de fato livres é
+// decidido por Consulta.Planejar.
+//
+// Normalmente os horários seguem a grade do intervalo a partir da abertura. Com
+// MinimizarLacunas, só são candidatos horários encostados em algo que já limita
+// o dia (abertura, fechamento, pausas e outros agendamentos), para não sobrar
+// buracos pequenos demais para vender. Nesse modo, Duracao deve ser a da visita
+// inteira, para encostar o fim dela no que vem depois.
+func Candidatos(data time.Time, horario *models.HorarioDia, regra Regra, agendamentos []models.Agendamento) []time.Time {
+	inicioDia := HorarioNaData(data, horario.Inicio)
+	fimDia := HorarioNaData(data, horario.Fim)
+
+	var candidatos []time.Time
+	if !regra.MinimizarLacunas {
+		for slot := inicioDia; slot.Before(fimDia); slot = slot.Add(regra.Intervalo) {
+			candidatos = append(candidatos, slot)
+		}
+		return candidatos
+	}
+
+	// Começando logo depois de algo, ou terminando logo antes; a preparação e a
+	// limpeza também precisam caber no expediente, como em Calendario.Validar
+	candidatos = append(candidatos, inicioDia.Add(regra.BufferAntes), fimDia.Add(-regra.Duracao-regra.BufferDepois))
+	// Se a antecedência mínima já consumiu o começo do dia, o primeiro horário da grade depois dela
+	if regra.Primeiro.After(inicioDia) {
+		passos := (regra.Primeiro.Sub(inicioDia) + regra.Intervalo - 1) / regra.Intervalo
+		candidatos = append(candidatos, inicioDia.Add(passos*regra.Intervalo))
+	}
+	for _, pausa := range horario.Pausas {
+		candidatos = append(candidatos,
+			HorarioNaData(data, pausa.Fim).Add(regra.BufferAntes),
+			HorarioNaData(data, pausa.Inicio).Add(-regra.Duracao-regra.BufferDepois))
+	}
+	for _, a := range agendamentos {
+		candidatos = append(candidatos,
+			a.BloqueioFim.Add(regra.BufferAntes),
+			a.BloqueioInicio.Add(-regra.BufferDepois-regra.Duracao))
+	}
+	sort.Slice(candidatos, func(i, j int) bool { return candidatos[i].Before(candidatos[j]) })
+
+	var unicos []time.Time
+	for _, slot := range candidatos {
+		if len(unicos) == 0 || !slot.Equal(unicos[len(unicos)-1]) {
+			unicos = append(unicos, slot)
+		}
+	}
+	return unicos
+}
@@ -0,0 +1,109 @@
+package disponibilidade
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/models"
+)
+
+// segunda é a data usada nos testes: 10/03/2025, uma segunda-feira em São Paulo.
+func segunda(t *testing.T) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatalf("fuso indisponível: %v", err)
+	}
+	return time.Date(2025, 3, 10, 0, 0, 0, 0, loc)
+}
+
+// ocupado monta o trecho de um agendamento já reservado na data.
+func ocupado(data time.Time, id int, funcionarioID *int, inicio, fim string) models.Agendamento {
+	i, f := HorarioNaData(data, inicio), HorarioNaData(data, fim)
+	return models.Agendamento{ID: id, FuncionarioID: funcionarioID, DataHoraInicio: i, DataHoraFim: f, BloqueioInicio: i, BloqueioFim: f}
+}
+
+func TestCandidatos(t *testing.T) {
+	data := segunda(t)
+	manha := &models.HorarioDia{Inicio: "09:00", Fim: "12:00"}
+	comPausa := &models.HorarioDia{Inicio: "09:00", Fim: "18:00", Pausas: []models.Pausa{{Inicio: "12:00", Fim: "13:00"}}}
+
+	casos := []struct {
+		nome         string
+		horario      *models.HorarioDia
+		regra        Regra
+		agendamentos []models.Agendamento
+		esperado     []string
+	}{
+		{
+			// A grade vai até o fechamento; quem não cabe é recusado por Consulta.Planejar
+			nome:     "grade do intervalo a partir da abertura",
+			horario:  manha,
+			regra:    Regra{Duracao: time.Hour, Intervalo: 45 * time.Minute},
+			esperado: []string{"09:00", "09:45", "10:30", "11:15"},
+		},
+		{
+			nome:     "grade ignora pausas e agendamentos",
+			horario:  comPausa,
+			regra:    Regra{Duracao: time.Hour, Intervalo: 3 * time.Hour},
+			esperado: []string{"09:00", "12:00", "15:00"},
+			agendamentos: []models.Agendamento{
+				ocupado(data, 1, nil, "15:00", "16:00"),
+			},
+		},
+		{
+			nome:     "lacunas mínimas encostam na abertura e no fechamento",
+			horario:  manha,
+			regra:    Regra{Duracao: time.Hour, Intervalo: 15 * time.Minute, MinimizarLacunas: true},
+			esperado: []string{"09:00", "11:00"},
+		},
+		{
+			nome:     "lacunas mínimas encostam nas pausas",
+			horario:  comPausa,
+			regra:    Regra{Duracao: time.Hour, Intervalo: 15 * time.Minute, MinimizarLacunas: true},
+			esperado: []string{"09:00", "11:00", "13:00", "17:00"},
+		},
+		{
+			nome:    "lacunas mínimas deixam espaço para preparação e limpeza",
+			horario: comPausa,
+			regra: Regra{Duracao: time.Hour, Intervalo: 15 * time.Minute, MinimizarLacunas: true,
+				BufferAntes: 10 * time.Minute, BufferDepois: 15 * time.Minute},
+			esperado: []string{"09:10", "10:45", "13:10", "16:45"},
+		},
+		{
+			nome:     "lacunas mínimas encostam nos agendamentos",
+			horario:  manha,
+			regra:    Regra{Duracao: 30 * time.Minute, Intervalo: 15 * time.Minute, MinimizarLacunas: true},
+			esperado: []string{"09:00", "09:30", "11:00", "11:30"},
+			agendamentos: []models.Agendamento{
+				ocupado(data, 1, nil, "10:00", "11:00"),
+			},
+		},
+		{
+			nome:     "horários repetidos aparecem uma vez",
+			horario:  manha,
+			regra:    Regra{Duracao: time.Hour, Intervalo: 15 * time.Minute, MinimizarLacunas: true},
+			esperado: []string{"08:00", "09:00", "10:00", "11:00"},
+			agendamentos: []models.Agendamento{
+				ocupado(data, 1, nil, "09:00", "10:00"),
+			},
+		},
+		{
+			nome:    "antecedência mínima acrescenta o primeiro horário da grade depois dela",
+			horario: manha,
+			regra: Regra{Duracao: time.Hour, Intervalo: 15 * time.Minute, MinimizarLacunas: true,
+				Primeiro: HorarioNaData(data, "09:20")},
+			esperado: []string{"09:00", "09:30", "11:00"},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			got := formatar(Candidatos(data, c.horario, c.regra, c.agendamentos))
+			if !slices.Equal(got, c.esperado) {
+				t.Errorf("Candidatos = %v, esperado %v", got, c.esperado)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// Package feriados traz o calendário de feriados nacionais do Brasil, embutido
+// no binário para que a importação não dependa de serviços externos.
+package feriados
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// nacionais.json lista, por ano, os feriados nacionais (Lei 662/1949 e
+// alterações, incluindo a Consciência Negra desde 2024). Pontos facultativos
+// como Carnaval e Corpus Christi ficam de fora. Para cobrir novos anos, basta
+// acrescentar as datas ao arquivo.
+//
+//go:embed nacionais.json
+var nacionaisJSON []byte
+
+// Feriado é uma data sem expediente em todo o país.
+type Feriado struct {
+	Data string `json:"data"` // AAAA-MM-DD
+	Nome string `json:"nome"`
+}
+
+var porAno map[string][]Feriado
+
+func init() {
+	if err := json.Unmarshal(nacionaisJSON, &porAno); err != nil {
+		panic(fmt.Sprintf("calendário de feriados inválido: %v", err))
+	}
+}
+
+// Nacionais devolve os feriados nacionais do ano, em ordem de data. ok é
+// falso se o ano não estiver no calendário embutido.
+func Nacionais(ano int) (lista []Feriado, ok bool) {
+	lista, ok = porAno[strconv.Itoa(ano)]
+	return lista, ok
+}
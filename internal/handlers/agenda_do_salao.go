@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/disponibilidade"
+	"github.com/emaildoissa/agenda-flow/internal/models"
+)
+
+// Tamanho das páginas da listagem de agendamentos
+const (
+	limitePadraoListagem = 50
+	limiteMaximoListagem = 200
+)
+
+// statusValidos são os status aceitos no filtro da listagem.
+var statusValidos = map[string]bool{
+	models.StatusPendente:      true,
+	models.StatusConfirmado:    true,
+	models.StatusConcluido:     true,
+	models.StatusNaoCompareceu: true,
+	models.StatusCancelado:     true,
+}
+
+// ListAgendamentos lista os agendamentos do salão para o proprietário, com
+// os serviços de cada um. Filtros opcionais: de e ate (datas no fuso do salão,
+// inclusivas), status (lista separada por vírgulas), funcionarioId e servicoId
+// (qualquer serviço da visita) e busca (trecho do nome ou do contato do
+// cliente). A ordem é pelo início, crescente ou, com ordem=desc, decrescente.
+// As páginas têm até limite itens; proximo_cursor, enviado como cursor, traz
+// a página seguinte.
+func (h *AgendamentosHandler) ListAgendamentos(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+
+	loc, err := fusoDoSalao(r.Context(), h.DB, salaoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Salão não encontrado", http.StatusNotFound)
+		} else {
+			log.Printf("Erro ao buscar fuso do salão %d: %v", salaoID, err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Os filtros são acumulados em condições com parâmetros numerados
+	condicoes := []string{"a.salao_id = $1"}
+	args := []any{salaoID}
+	filtrar := func(condicao string, valor any) {
+		args = append(args, valor)
+		condicoes = append(condicoes, strings.ReplaceAll(condicao, "$?", "$"+strconv.Itoa(len(args))))
+	}
+
+	// O período vira um intervalo de instantes, para a consulta usar o índice (salao_id, data_hora_inicio)
+	if deStr := q.Get("de"); deStr != "" {
+		de, err := time.Parse("2006-01-02", deStr)
+		if err != nil {
+			http.Error(w, "Parâmetro 'de' inválido. Use o formato AAAA-MM-DD", http.StatusBadRequest)
+			return
+		}
+		filtrar("a.data_hora_inicio >= $?", disponibilidade.MeiaNoite(de, loc))
+	}
+	if ateStr := q.Get("ate"); ateStr != "" {
+		ate, err := time.Parse("2006-01-02", ateStr)
+		if err != nil {
+			http.Error(w, "Parâmetro 'ate' inválido. Use o formato AAAA-MM-DD", http.StatusBadRequest)
+			return
+		}
+		filtrar("a.data_hora_inicio < $?", disponibilidade.SomarDias(disponibilidade.MeiaNoite(ate, loc), 1))
+	}
+	if statusStr := q.Get("status"); statusStr != "" {
+		status, msg := lerStatus(statusStr)
+		if msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		filtrar("a.status = ANY($?)", status)
+	}
+	if idStr := q.Get("funcionarioId"); idStr != "" {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "ID de funcionário inválido", http.StatusBadRequest)
+			return
+		}
+		filtrar("EXISTS (SELECT 1 FROM agendamento_servicos ags WHERE ags.agendamento_id = a.id AND ags.funcionario_id = $?)", id)
+	}
+	if idStr := q.Get("servicoId"); idStr != "" {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "ID de serviço inválido", http.StatusBadRequest)
+			return
+		}
+		filtrar("EXISTS (SELECT 1 FROM agendamento_servicos ags WHERE ags.agendamento_id = a.id AND ags.servico_id = $?)", id)
+	}
+	if busca := strings.TrimSpace(q.Get("busca")); busca != "" {
+		filtrar("(a.cliente_nome ILIKE $? OR a.cliente_contato ILIKE $?)", "%"+escaparLike(busca)+"%")
+	}
+
+	decrescente := false
+	switch q.Get("ordem") {
+	case "", "asc":
+	case "desc":
+		decrescente = true
+	default:
+		http.Error(w, "Parâmetro 'ordem' inválido. Use asc ou desc", http.StatusBadRequest)
+		return
+	}
+
+	limite := limitePadraoListagem
+	if limiteStr := q.Get("limite"); limiteStr != "" {
+		limite, err = strconv.Atoi(limiteStr)
+		if err != nil || limite < 1 || limite > limiteMaximoListagem {
+			http.Error(w, fmt.Sprintf("Parâmetro 'limite' inválido. Use de 1 a %d", limiteMaximoListagem), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// O cursor é a posição (início, id) do último item da página anterior
+	if cursor := q.Get("cursor"); cursor != "" {
+		inicio, id, err := lerCursor(cursor)
+		if err != nil {
+			http.Error(w, "Cursor inválido", http.StatusBadRequest)
+			return
+		}
+		comparacao := ">"
+		if decrescente {
+			comparacao = "<"
+		}
+		args = append(args, inicio, id)
+		condicoes = append(condicoes, fmt.Sprintf("(a.data_hora_inicio, a.id) %s ($%d, $%d)", comparacao, len(args)-1, len(args)))
+	}
+
+	direcao := "ASC"
+	if decrescente {
+		direcao = "DESC"
+	}
+	// Um item a mais diz se existe a próxima página
+	args = append(args, limite+1)
+	rows, err := h.DB.QueryContext(r.Context(), fmt.Sprintf(`
+		SELECT a.id, a.salao_id, a.servico_id, a.funcionario_id, a.cliente_nome, a.cliente_contato,
+		       a.data_hora_inicio, a.data_hora_fim, a.status, a.expira_em, a.reagendamentos, a.criado_em
+		FROM agendamentos a
+		WHERE %s
+		ORDER BY a.data_hora_inicio %s, a.id %s
+		LIMIT $%d`,
+		strings.Join(condicoes, " AND "), direcao, direcao, len(args)),
+		args...)
+	if err != nil {
+		log.Printf("Erro ao listar agendamentos: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	pagina := models.PaginaAgendamentos{Agendamentos: []models.Agendamento{}}
+	for rows.Next() {
+		var a models.Agendamento
+		if err := rows.Scan(&a.ID, &a.SalaoID, &a.ServicoID, &a.FuncionarioID, &a.ClienteNome, &a.ClienteContato,
+			&a.DataHoraInicio, &a.DataHoraFim, &a.Status, &a.ExpiraEm, &a.Reagendamentos, &a.CriadoEm); err != nil {
+			log.Printf("Erro ao escanear agendamento: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			return
+		}
+		pagina.Agendamentos = append(pagina.Agendamentos, a)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Erro ao listar agendamentos: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	if len(pagina.Agendamentos) > limite {
+		pagina.Agendamentos = pagina.Agendamentos[:limite]
+		ultimo := pagina.Agendamentos[limite-1]
+		pagina.ProximoCursor = gerarCursor(ultimo.DataHoraInicio, ultimo.ID)
+	}
+
+	if err := h.preencherServicos(r.Context(), pagina.Agendamentos); err != nil {
+		log.Printf("Erro ao buscar serviços dos agendamentos: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(pagina)
+}
+
+// GetAgendaDoDia devolve a agenda de um dia (data, no fuso do salão) numa
+// visão compacta: uma coluna por profissional ativo, mesmo sem atendimentos,
+// com os serviços em ordem de horário. Um combo feito por dois profissionais
+// aparece na coluna de cada um, com o trecho que cabe a ele. Sem o filtro
+// status, os cancelados ficam de fora.
+func (h *AgendamentosHandler) GetAgendaDoDia(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := time.Parse("2006-01-02", r.URL.Query().Get("data"))
+	if err != nil {
+		http.Error(w, "Formato de data inválido. Use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	status := []string{models.StatusPendente, models.StatusConfirmado, models.StatusConcluido, models.StatusNaoCompareceu}
+	if statusStr := r.URL.Query().Get("status"); statusStr != "" {
+		var msg string
+		if status, msg = lerStatus(statusStr); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+
+	loc, err := fusoDoSalao(r.Context(), h.DB, salaoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Salão não encontrado", http.StatusNotFound)
+		} else {
+			log.Printf("Erro ao buscar fuso do salão %d: %v", salaoID, err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+	inicioDia := disponibilidade.MeiaNoite(data, loc)
+
+	funcionarios, err := buscarFuncionariosAtivos(r.Context(), h.DB, salaoID)
+	if err != nil {
+		log.Printf("Erro ao buscar funcionários do salão %d: %v", salaoID, err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	agenda := models.AgendaDoDia{Data: data.Format("2006-01-02"), Profissionais: []models.AgendaProfissional{}}
+	colunas := make(map[int]int) // ID do profissional (0 = sem profissional) -> índice em Profissionais
+	for _, f := range funcionarios {
+		id := f.ID
+		colunas[id] = len(agenda.Profissionais)
+		agenda.Profissionais = append(agenda.Profissionais, models.AgendaProfissional{FuncionarioID: &id, Nome: f.Nome, Atendimentos: []models.AtendimentoDoDia{}})
+	}
+
+	// O dia é filtrado pelo início da visita, pelo índice (salao_id, data_hora_inicio)
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT a.id, a.cliente_nome, a.cliente_contato, a.status,
+		       ags.funcionario_id, f.nome, s.nome, ags.data_hora_inicio, ags.data_hora_fim
+		FROM agendamentos a
+		JOIN agendamento_servicos ags ON ags.agendamento_id = a.id
+		JOIN servicos s ON s.id = ags.servico_id
+		LEFT JOIN funcionarios f ON f.id = ags.funcionario_id
+		WHERE a.salao_id = $1 AND a.data_hora_inicio >= $2 AND a.data_hora_inicio < $3 AND a.status = ANY($4)
+		ORDER BY ags.data_hora_inicio, a.id, ags.posicao`,
+		salaoID, inicioDia, disponibilidade.SomarDias(inicioDia, 1), status)
+	if err != nil {
+		log.Printf("Erro ao buscar agenda do dia: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var at models.AtendimentoDoDia
+		var funcionarioID *int
+		var funcionarioNome *string
+		var inicio, fim time.Time
+		if err := rows.Scan(&at.AgendamentoID, &at.ClienteNome, &at.ClienteContato, &at.Status,
+			&funcionarioID, &funcionarioNome, &at.ServicoNome, &inicio, &fim); err != nil {
+			log.Printf("Erro ao escanear agenda do dia: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			return
+		}
+		at.Inicio = inicio.In(loc).Format("15:04")
+		at.Fim = fim.In(loc).Format("15:04")
+
+		// Profissionais desativados e a agenda sem profissional ganham a coluna ao aparecer
+		chave := 0
+		if funcionarioID != nil {
+			chave = *funcionarioID
+		}
+		i, ok := colunas[chave]
+		if !ok {
+			coluna := models.AgendaProfissional{FuncionarioID: funcionarioID, Atendimentos: []models.AtendimentoDoDia{}}
+			if funcionarioNome != nil {
+				coluna.Nome = *funcionarioNome
+			}
+			i = len(agenda.Profissionais)
+			colunas[chave] = i
+			agenda.Profissionais = append(agenda.Profissionais, coluna)
+		}
+		agenda.Profissionais[i].Atendimentos = append(agenda.Profissionais[i].Atendimentos, at)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Erro ao buscar agenda do dia: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(agenda)
+}
+
+// preencherServicos carrega numa única consulta os serviços dos agendamentos
+// da página, com os nomes do serviço e do profissional, e calcula o preço total.
+func (h *AgendamentosHandler) preencherServicos(ctx context.Context, agendamentos []models.Agendamento) error {
+	if len(agendamentos) == 0 {
+		return nil
+	}
+	indices := make(map[int]int, len(agendamentos))
+	ids := make([]int, 0, len(agendamentos))
+	for i, a := range agendamentos {
+		indices[a.ID] = i
+		ids = append(ids, a.ID)
+	}
+
+	rows, err := h.DB.QueryContext(ctx, `
+		SELECT ags.agendamento_id, ags.posicao, ags.servico_id, s.nome, ags.funcionario_id, f.nome,
+		       ags.data_hora_inicio, ags.data_hora_fim, ags.preco
+		FROM agendamento_servicos ags
+		JOIN servicos s ON s.id = ags.servico_id
+		LEFT JOIN funcionarios f ON f.id = ags.funcionario_id
+		WHERE ags.agendamento_id = ANY($1)
+		ORDER BY ags.agendamento_id, ags.posicao`,
+		ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var agendamentoID int
+		var item models.AgendamentoServico
+		if err := rows.Scan(&agendamentoID, &item.Posicao, &item.ServicoID, &item.ServicoNome, &item.FuncionarioID, &item.FuncionarioNome,
+			&item.DataHoraInicio, &item.DataHoraFim, &item.Preco); err != nil {
+			return err
+		}
+		a := &agendamentos[indices[agendamentoID]]
+		a.Servicos = append(a.Servicos, item)
+		a.PrecoTotal += item.Preco
+	}
+	return rows.Err()
+}
+
+// fusoDoSalao devolve o fuso do salão. Devolve sql.ErrNoRows se o salão não existir.
+func fusoDoSalao(ctx context.Context, db *sql.DB, salaoID int) (*time.Location, error) {
+	var fusoHorario string
+	if err := db.QueryRowContext(ctx, "SELECT fuso_horario FROM saloes WHERE id = $1", salaoID).Scan(&fusoHorario); err != nil {
+		return nil, err
+	}
+	return localizacaoDoSalao(fusoHorario), nil
+}
+
+// lerStatus interpreta uma lista de status separada por vírgulas. Devolve a
+// mensagem de erro, ou "" se todos forem válidos.
+func lerStatus(valor string) ([]string, string) {
+	var status []string
+	for _, s := range strings.Split(valor, ",") {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if !statusValidos[s] {
+			return nil, fmt.Sprintf("Status inválido: '%s'", s)
+		}
+		status = append(status, s)
+	}
+	return status, ""
+}
+
+// escaparLike protege os curingas do ILIKE num texto digitado pelo usuário.
+func escaparLike(texto string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(texto)
+}
+
+// gerarCursor codifica a posição de um item na listagem. O cursor é opaco
+// para o cliente, que só o devolve para pedir a próxima página.
+func gerarCursor(inicio time.Time, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(inicio.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(id)))
+}
+
+// lerCursor decodifica um cursor gerado por gerarCursor.
+func lerCursor(cursor string) (time.Time, int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	inicioStr, idStr, ok := strings.Cut(string(b), "|")
+	if !ok {
+		return time.Time{}, 0, errors.New("cursor sem separador")
+	}
+	inicio, err := time.Parse(time.RFC3339Nano, inicioStr)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return inicio, id, nil
+}
@@ -1,42 +1,43 @@
 package handlers
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/emaildoissa/agenda-flow/internal/agenda"
+	"github.com/emaildoissa/agenda-flow/internal/disponibilidade"
 	"github.com/emaildoissa/agenda-flow/internal/models"
+	"github.com/emaildoissa/agenda-flow/internal/webhooks"
 	"github.com/go-chi/chi/v5"
 )
 
-// AgendamentosHandler agora segura a URL do webhook do n8n
+// AgendamentosHandler gerencia os agendamentos. As notificações para o n8n
+// são gravadas na outbox e entregues pelo webhooks.Despachante.
 type AgendamentosHandler struct {
-	DB            *sql.DB
-	N8NWebhookURL string
+	DB          *sql.DB
+	Notificador *webhooks.Notificador
+	Relogio     agenda.Relogio // Hora atual usada na janela de reservas e nos prazos
 }
 
 // NewAgendamentosHandler é o construtor para nosso handler
-func NewAgendamentosHandler(db *sql.DB, n8nWebhookURL string) *AgendamentosHandler {
-	return &AgendamentosHandler{
-		DB:            db,
-		N8NWebhookURL: n8nWebhookURL,
-	}
-}
-
-// N8NPayload é a estrutura de dados que enviaremos para o n8n
-type N8NPayload struct {
-	AgendamentoID       int    `json:"agendamento_id"`
-	ClienteNome         string `json:"cliente_nome"`
-	ServicoNome         string `json:"servico_nome"`
-	DataHoraFormatada   string `json:"data_hora_formatada"`
-	WhatsappNotificacao string `json:"whatsapp_notificacao"`
+func NewAgendamentosHandler(db *sql.DB, notificador *webhooks.Notificador) *AgendamentosHandler {
+	return &AgendamentosHandler{DB: db, Notificador: notificador, Relogio: agenda.RelogioDoSistema{}}
 }
 
-// CreateAgendamento cria um agendamento e dispara o gatilho para o n8n
+// CreateAgendamento cria um agendamento e dispara o gatilho para o n8n. O
+// horário passa pelo mesmo motor de disponibilidade de GetDisponibilidade:
+// só é aceito um horário que a disponibilidade ofereceria. Uma visita com
+// vários serviços vem em servicos, cada um com um profissional opcional; sem
+// essa lista, valem servico_id e funcionario_id.
 func (h *AgendamentosHandler) CreateAgendamento(w http.ResponseWriter, r *http.Request) {
 	var agendamento models.Agendamento
 	err := json.NewDecoder(r.Body).Decode(&agendamento)
@@ -44,134 +45,652 @@ func (h *AgendamentosHandler) CreateAgendamento(w http.ResponseWriter, r *http.R
 		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
 		return
 	}
+	if campos := validarNovoAgendamento(agendamento); len(campos) > 0 {
+		responderErrosDeCampo(w, http.StatusBadRequest, campos)
+		return
+	}
+
+	pedidos := agendamento.Servicos
+	if len(pedidos) == 0 {
+		pedidos = []models.AgendamentoServico{{ServicoID: agendamento.ServicoID, FuncionarioID: agendamento.FuncionarioID}}
+	}
+	// Os erros apontam o campo como o cliente o enviou
+	campoDaEtapa := func(i int, campo string) string {
+		if len(agendamento.Servicos) == 0 {
+			return campo
+		}
+		return fmt.Sprintf("servicos[%d].%s", i, campo)
+	}
+
+	etapas := make([]etapaPedida, 0, len(pedidos))
+	agendaEscolhida := true // Cada serviço tem um profissional definido pelo cliente
+	for i, pedido := range pedidos {
+		servico, err := buscarServico(r.Context(), h.DB, agendamento.SalaoID, pedido.ServicoID)
+		if err != nil || !servico.Ativo {
+			responderErroDeCampo(w, http.StatusBadRequest, campoDaEtapa(i, "servico_id"), "Serviço inválido")
+			return
+		}
+		etapas = append(etapas, etapaPedida{Servico: servico, FuncionarioID: pedido.FuncionarioID})
+		agendaEscolhida = agendaEscolhida && pedido.FuncionarioID != nil
+	}
 
-	var duracaoMinutos int
-	var nomeServico string
-	err = h.DB.QueryRow("SELECT nome, duracao_minutos FROM servicos WHERE id = $1 AND salao_id = $2", agendamento.ServicoID, agendamento.SalaoID).Scan(&nomeServico, &duracaoMinutos)
+	cal, err := carregarCalendario(r.Context(), h.DB, agendamento.SalaoID)
 	if err != nil {
-		http.Error(w, "Serviço inválido", http.StatusBadRequest)
+		if errors.Is(err, sql.ErrNoRows) {
+			responderErroDeCampo(w, http.StatusBadRequest, "salao_id", "Salão inválido")
+		} else {
+			log.Printf("Erro ao carregar expediente do salão %d: %v", agendamento.SalaoID, err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	var whatsappNotificacao string
-	err = h.DB.QueryRow("SELECT whatsapp_notificacao FROM saloes WHERE id = $1", agendamento.SalaoID).Scan(&whatsappNotificacao)
+	inicio := agendamento.DataHoraInicio
+	agora := h.Relogio.Agora()
+	dia := disponibilidade.MeiaNoite(inicio.In(cal.Loc), cal.Loc)
+	consulta, err := carregarConsulta(r.Context(), h.DB, cal, agendamento.SalaoID, etapas, dia, dia, agora, 0)
+	var errEtapa *erroEtapa
+	if errors.As(err, &errEtapa) && errors.Is(err, errFuncionarioNaoEncontrado) {
+		responderErroDeCampo(w, http.StatusBadRequest, campoDaEtapa(errEtapa.Indice, "funcionario_id"), "Funcionário inválido")
+		return
+	}
+	if err != nil {
+		log.Printf("Erro ao carregar disponibilidade do salão %d: %v", agendamento.SalaoID, err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	if !consulta.SemFuncionarios {
+		for i, etapa := range consulta.Etapas {
+			if len(etapa.Funcionarios) > 0 {
+				continue
+			}
+			if pedidos[i].FuncionarioID != nil {
+				responderErroDeCampo(w, http.StatusUnprocessableEntity, campoDaEtapa(i, "funcionario_id"), "O profissional não realiza este serviço")
+			} else {
+				responderErroDeCampo(w, http.StatusUnprocessableEntity, campoDaEtapa(i, "servico_id"), "Nenhum profissional realiza este serviço")
+			}
+			return
+		}
+	}
+
+	// Salões em modo de aprovação manual recebem uma pré-reserva, que segura o
+	// horário até expira_em; se o proprietário não confirmar, ela é cancelada.
+	agendamento.Status = models.StatusConfirmado
+	agendamento.ExpiraEm = nil
+	if consulta.Config.ModoConfirmacao == models.ModoConfirmacaoManual {
+		expiraEm := agora.Add(time.Duration(consulta.Config.MinutosReservaPendente) * time.Minute)
+		agendamento.Status = models.StatusPendente
+		agendamento.ExpiraEm = &expiraEm
+	}
+
+	// "Qualquer profissional disponível": o motor escolhe, para cada serviço, o
+	// profissional livre menos ocupado no dia
+	for tentativa := 1; ; tentativa++ {
+		plano, recusa := consulta.Planejar(inicio)
+		if recusa != nil {
+			responderRecusa(w, recusa, consulta.Config, agendaEscolhida || consulta.SemFuncionarios)
+			return
+		}
+		aplicarPlano(&agendamento, plano)
+		err = h.inserirAgendamento(r.Context(), &agendamento)
+		// A constraint de exclusão garante que duas requisições simultâneas não
+		// consigam reservar o mesmo horário; nesse caso, relemos a agenda e
+		// tentamos encaixar a visita com outros profissionais.
+		if !ehConflitoDeHorario(err) || tentativa == maxTentativasReserva {
+			break
+		}
+		consulta.Agendamentos, err = buscarAgendamentosDoPeriodo(r.Context(), h.DB, agendamento.SalaoID, dia, disponibilidade.SomarDias(dia, 1), 0)
+		if err != nil {
+			break
+		}
+	}
+
+	if ehConflitoDeHorario(err) {
+		conflito, errConflito := buscarConflitoDaVisita(r.Context(), h.DB, agendamento.SalaoID, agendamento.Servicos, 0)
+		if errConflito != nil {
+			responderConflito(w, nil)
+			return
+		}
+		responderConflito(w, &conflito)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Salão inválido", http.StatusBadRequest)
+		log.Printf("Erro ao inserir agendamento: %v", err)
+		http.Error(w, "Erro ao criar agendamento", http.StatusInternalServerError)
 		return
 	}
 
-	agendamento.DataHoraFim = agendamento.DataHoraInicio.Add(time.Duration(duracaoMinutos) * time.Minute)
-	agendamento.Status = "CONFIRMADO"
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(agendamento)
+}
+
+// maxTentativasReserva limita quantas vezes a criação tenta de novo quando
+// outra reserva ocupa o horário entre a consulta e a gravação.
+const maxTentativasReserva = 3
+
+// responderRecusa explica por que o motor não encaixou a visita no horário.
+// Quando a agenda foi escolhida pelo cliente (ou é a única do salão), o
+// conflito é com um agendamento determinado, que mostramos.
+func responderRecusa(w http.ResponseWriter, recusa error, config models.ConfiguracoesSalao, agendaEscolhida bool) {
+	if !errors.Is(recusa, disponibilidade.ErrHorarioOcupado) {
+		responderErroDeCampo(w, http.StatusUnprocessableEntity, "data_hora_inicio", mensagemRecusa(recusa, config, agendaEscolhida))
+		return
+	}
+	var conflito *disponibilidade.ConflitoError
+	if agendaEscolhida && errors.As(recusa, &conflito) {
+		responderConflito(w, &conflito.Ocupado)
+		return
+	}
+	responderConflito(w, nil)
+}
+
+// aplicarPlano preenche o agendamento com os serviços encaixados pelo motor.
+// O primeiro serviço e o seu profissional identificam o agendamento, e os
+// horários dele cobrem a visita inteira.
+func aplicarPlano(agendamento *models.Agendamento, plano []disponibilidade.Alocacao) {
+	agendamento.Servicos = make([]models.AgendamentoServico, 0, len(plano))
+	agendamento.PrecoTotal = 0
+	for i, p := range plano {
+		item := models.AgendamentoServico{
+			Posicao:        i + 1,
+			ServicoID:      p.Servico.ID,
+			ServicoNome:    p.Servico.Nome,
+			DataHoraInicio: p.Inicio,
+			DataHoraFim:    p.Fim,
+			Preco:          p.Preco,
+			BloqueioInicio: p.BloqueioInicio,
+			BloqueioFim:    p.BloqueioFim,
+		}
+		if p.Funcionario != nil {
+			id := p.Funcionario.ID
+			item.FuncionarioID = &id
+		}
+		agendamento.Servicos = append(agendamento.Servicos, item)
+		agendamento.PrecoTotal += p.Preco
+	}
+
+	primeiro, ultimo := agendamento.Servicos[0], agendamento.Servicos[len(plano)-1]
+	agendamento.ServicoID, agendamento.FuncionarioID = primeiro.ServicoID, primeiro.FuncionarioID
+	agendamento.DataHoraInicio, agendamento.DataHoraFim = primeiro.DataHoraInicio, ultimo.DataHoraFim
+	agendamento.BloqueioInicio, agendamento.BloqueioFim = primeiro.BloqueioInicio, ultimo.BloqueioFim
+	for _, item := range agendamento.Servicos {
+		if item.BloqueioInicio.Before(agendamento.BloqueioInicio) {
+			agendamento.BloqueioInicio = item.BloqueioInicio
+		}
+		if item.BloqueioFim.After(agendamento.BloqueioFim) {
+			agendamento.BloqueioFim = item.BloqueioFim
+		}
+	}
+}
+
+// inserirAgendamento grava o agendamento e os seus serviços, preenchendo ID e
+// data de criação, e enfileira a notificação para o n8n na mesma transação.
+func (h *AgendamentosHandler) inserirAgendamento(ctx context.Context, agendamento *models.Agendamento) error {
+	token, err := gerarTokenGestao()
+	if err != nil {
+		return err
+	}
+	agendamento.TokenGestao = token
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
 	sqlStatement := `
-		INSERT INTO agendamentos (salao_id, servico_id, cliente_nome, cliente_contato, data_hora_inicio, data_hora_fim, status)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO agendamentos (salao_id, servico_id, funcionario_id, cliente_nome, cliente_contato, data_hora_inicio, data_hora_fim,
+		                          bloqueio_inicio, bloqueio_fim, status, expira_em, token_gestao)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 		RETURNING id, criado_em`
 
-	err = h.DB.QueryRow(
+	err = tx.QueryRowContext(
+		ctx,
 		sqlStatement,
-		agendamento.SalaoID, agendamento.ServicoID, agendamento.ClienteNome, agendamento.ClienteContato,
-		agendamento.DataHoraInicio, agendamento.DataHoraFim, agendamento.Status,
+		agendamento.SalaoID, agendamento.ServicoID, agendamento.FuncionarioID, agendamento.ClienteNome, agendamento.ClienteContato,
+		agendamento.DataHoraInicio, agendamento.DataHoraFim, agendamento.BloqueioInicio, agendamento.BloqueioFim,
+		agendamento.Status, agendamento.ExpiraEm, agendamento.TokenGestao,
 	).Scan(&agendamento.ID, &agendamento.CriadoEm)
+	if err != nil {
+		return err
+	}
+
+	// A constraint de exclusão de agendamento_servicos é que recusa horários já ocupados
+	for _, item := range agendamento.Servicos {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO agendamento_servicos (agendamento_id, posicao, salao_id, servico_id, funcionario_id,
+			                                  data_hora_inicio, data_hora_fim, bloqueio_inicio, bloqueio_fim, preco)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			agendamento.ID, item.Posicao, agendamento.SalaoID, item.ServicoID, item.FuncionarioID,
+			item.DataHoraInicio, item.DataHoraFim, item.BloqueioInicio, item.BloqueioFim, item.Preco)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := agenda.RegistrarHistorico(ctx, tx, agendamento.ID, nil, agendamento.Status, "", agenda.AutorCliente); err != nil {
+		return err
+	}
+	if err := h.Notificador.NotificarAgendamento(ctx, tx, webhooks.EventoAgendamentoCriado, agendamento.ID, nil); err != nil {
+		return err
+	}
+	if err := agenda.AgendarLembretes(ctx, tx, agendamento.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
 
+type alterarStatusRequest struct {
+	Motivo string `json:"motivo"`
+	// Cancelamento feito pelo salão a pedido do cliente; conta como tardio
+	// se estiver dentro do prazo da política
+	AtribuirAoCliente bool `json:"atribuir_ao_cliente"`
+}
+
+// updateAgendamentoStatus aplica uma transição da máquina de estados.
+// Transições não permitidas a partir do status atual resultam em 409.
+func (h *AgendamentosHandler) updateAgendamentoStatus(w http.ResponseWriter, r *http.Request, novoStatus string) {
+	salaoID, err := salaoDoEscopo(r)
 	if err != nil {
-		log.Printf("Erro ao inserir agendamento: %v", err)
-		http.Error(w, "Erro ao criar agendamento", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Prepara os dados para o n8n
-	location, _ := time.LoadLocation("America/Sao_Paulo")
-	payload := N8NPayload{
-		AgendamentoID:       agendamento.ID,
-		ClienteNome:         agendamento.ClienteNome,
-		ServicoNome:         nomeServico,
-		DataHoraFormatada:   agendamento.DataHoraInicio.In(location).Format("15:04 de 02/01/2006"),
-		WhatsappNotificacao: whatsappNotificacao,
+	agendamentoIDStr := chi.URLParam(r, "idAgendamento")
+	agendamentoID, err := strconv.Atoi(agendamentoIDStr)
+	if err != nil {
+		http.Error(w, "ID de agendamento inválido", http.StatusBadRequest)
+		return
 	}
 
-	// Dispara o webhook em uma goroutine para não bloquear a resposta ao usuário
-	go h.dispararWebhookN8N(payload)
+	// O corpo serve para informar o motivo, obrigatório só nos cancelamentos
+	var req alterarStatusRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+			return
+		}
+	}
+	if novoStatus == models.StatusCancelado && strings.TrimSpace(req.Motivo) == "" {
+		responderErroDeCampo(w, http.StatusBadRequest, "motivo", "Informe o motivo do cancelamento")
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(agendamento)
+	tx, err := h.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Erro ao iniciar transação: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// O filtro por salao_id faz com que agendamentos de outro salão resultem em 404
+	statusAnterior, err := agenda.Transicionar(r.Context(), tx, agenda.Alteracao{
+		AgendamentoID: agendamentoID,
+		SalaoID:       salaoID,
+		NovoStatus:    novoStatus,
+		Motivo:        req.Motivo,
+		AlteradoPor:   agenda.AutorProprietario(salaoID),
+		// Só faz sentido para cancelamentos
+		AtribuidoAoCliente: req.AtribuirAoCliente && novoStatus == models.StatusCancelado,
+		Relogio:            h.Relogio,
+		Notificador:        h.Notificador,
+	})
+	if !responderErroTransicao(w, err, statusAnterior, novoStatus) {
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Erro ao confirmar transação: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "sucesso", "status_anterior": statusAnterior, "novo_status": novoStatus})
+}
+
+// responderErroTransicao traduz os erros de agenda.Transicionar para HTTP.
+// Devolve true se não houve erro e o handler pode continuar.
+func responderErroTransicao(w http.ResponseWriter, err error, statusAtual, novoStatus string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, agenda.ErrAgendamentoNaoEncontrado):
+		http.Error(w, "Agendamento não encontrado", http.StatusNotFound)
+	case errors.Is(err, agenda.ErrTransicaoInvalida):
+		http.Error(w, fmt.Sprintf("Transição não permitida: de %s para %s", statusAtual, novoStatus), http.StatusConflict)
+	case errors.Is(err, agenda.ErrForaDoPrazo):
+		http.Error(w, "Prazo para cancelamento encerrado: "+strings.TrimPrefix(err.Error(), agenda.ErrForaDoPrazo.Error()+": "), http.StatusUnprocessableEntity)
+	default:
+		log.Printf("Erro ao alterar status do agendamento: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+	}
+	return false
+}
+
+func (h *AgendamentosHandler) ConfirmarAgendamento(w http.ResponseWriter, r *http.Request) {
+	h.updateAgendamentoStatus(w, r, models.StatusConfirmado)
+}
+
+func (h *AgendamentosHandler) CancelarAgendamento(w http.ResponseWriter, r *http.Request) {
+	h.updateAgendamentoStatus(w, r, models.StatusCancelado)
+}
+
+func (h *AgendamentosHandler) ConcluirAgendamento(w http.ResponseWriter, r *http.Request) {
+	h.updateAgendamentoStatus(w, r, models.StatusConcluido)
 }
 
-// dispararWebhookN8N envia os dados do agendamento para a URL do webhook do n8n.
-func (h *AgendamentosHandler) dispararWebhookN8N(payload N8NPayload) {
-	if h.N8NWebhookURL == "" {
-		log.Println("AVISO: URL do webhook do n8n não configurada. Pulando notificação.")
+func (h *AgendamentosHandler) MarcarNaoCompareceu(w http.ResponseWriter, r *http.Request) {
+	h.updateAgendamentoStatus(w, r, models.StatusNaoCompareceu)
+}
+
+// GetHistorico lista as mudanças de um agendamento, da mais antiga para a mais recente.
+func (h *AgendamentosHandler) GetHistorico(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	agendamentoID, err := strconv.Atoi(chi.URLParam(r, "idAgendamento"))
+	if err != nil {
+		http.Error(w, "ID de agendamento inválido", http.StatusBadRequest)
 		return
 	}
 
-	payloadBytes, err := json.Marshal(payload)
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT h.id, h.agendamento_id, h.status_anterior, h.status_novo, h.motivo, h.alterado_por, h.alterado_em
+		FROM agendamento_historico h
+		JOIN agendamentos a ON a.id = h.agendamento_id
+		WHERE h.agendamento_id = $1 AND a.salao_id = $2
+		ORDER BY h.id`,
+		agendamentoID, salaoID)
 	if err != nil {
-		log.Printf("Erro ao serializar payload para o n8n: %v", err)
+		log.Printf("Erro ao buscar histórico: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
 		return
 	}
+	defer rows.Close()
+
+	historico := make([]models.HistoricoAgendamento, 0)
+	for rows.Next() {
+		var item models.HistoricoAgendamento
+		if err := rows.Scan(&item.ID, &item.AgendamentoID, &item.StatusAnterior, &item.StatusNovo, &item.Motivo, &item.AlteradoPor, &item.AlteradoEm); err != nil {
+			log.Printf("Erro ao escanear histórico: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			return
+		}
+		historico = append(historico, item)
+	}
+	// Todo agendamento tem ao menos a linha da criação; lista vazia significa outro salão
+	if len(historico) == 0 {
+		http.Error(w, "Agendamento não encontrado", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(historico)
+}
 
-	req, err := http.NewRequest("POST", h.N8NWebhookURL, bytes.NewBuffer(payloadBytes))
+type reagendarRequest struct {
+	DataHoraInicio time.Time `json:"data_hora_inicio"`
+}
+
+// ReagendarAgendamento move um agendamento ativo para outro horário, mantendo
+// os serviços e os profissionais, e avisa o cliente com o evento agendamento.reagendado.
+func (h *AgendamentosHandler) ReagendarAgendamento(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
 	if err != nil {
-		log.Printf("Erro ao criar requisição para o n8n: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	agendamentoID, err := strconv.Atoi(chi.URLParam(r, "idAgendamento"))
 	if err != nil {
-		log.Printf("Erro ao enviar webhook para o n8n: %v", err)
+		http.Error(w, "ID de agendamento inválido", http.StatusBadRequest)
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= 400 {
-		log.Printf("Erro do n8n ao receber webhook. Status: %s", resp.Status)
+	var req reagendarRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.DataHoraInicio.IsZero() {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("Webhook enviado para o n8n com sucesso. Status: %s", resp.Status)
+	h.reagendar(w, r, salaoID, agendamentoID, req.DataHoraInicio, agenda.AutorProprietario(salaoID), false)
 }
 
-// As funções abaixo não foram modificadas
-func (h *AgendamentosHandler) updateAgendamentoStatus(w http.ResponseWriter, r *http.Request, novoStatus string) {
-	agendamentoIDStr := chi.URLParam(r, "idAgendamento")
-	agendamentoID, err := strconv.Atoi(agendamentoIDStr)
+// reagendar move o agendamento para novoInicio, planejando a visita pelo mesmo
+// motor da criação: o horário precisa ser um dos que a disponibilidade
+// ofereceria, dentro da janela de reservas. É usado tanto pelo proprietário
+// quanto pelo cliente (via token de gestão); com aplicarPolitica, valem também o
+// prazo da política de cancelamento e o limite de reagendamentos do salão.
+func (h *AgendamentosHandler) reagendar(w http.ResponseWriter, r *http.Request, salaoID, agendamentoID int, novoInicio time.Time, autor string, aplicarPolitica bool) {
+	tx, err := h.DB.BeginTx(r.Context(), nil)
 	if err != nil {
-		http.Error(w, "ID de agendamento inválido", http.StatusBadRequest)
+		log.Printf("Erro ao iniciar transação: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
 		return
 	}
+	defer tx.Rollback()
 
-	sqlStatement := `UPDATE agendamentos SET status = $1 WHERE id = $2`
-	res, err := h.DB.Exec(sqlStatement, novoStatus, agendamentoID)
+	var agendamento models.Agendamento
+	err = tx.QueryRowContext(r.Context(), `
+		SELECT id, servico_id, funcionario_id, data_hora_inicio, data_hora_fim, status, reagendamentos FROM agendamentos
+		WHERE id = $1 AND salao_id = $2
+		FOR UPDATE`,
+		agendamentoID, salaoID).Scan(&agendamento.ID, &agendamento.ServicoID, &agendamento.FuncionarioID, &agendamento.DataHoraInicio, &agendamento.DataHoraFim,
+		&agendamento.Status, &agendamento.Reagendamentos)
 	if err != nil {
-		log.Printf("Erro ao atualizar status do agendamento: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Agendamento não encontrado", http.StatusNotFound)
+		} else {
+			log.Printf("Erro ao buscar agendamento: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+	if !models.StatusAtivo(agendamento.Status) {
+		http.Error(w, "Somente agendamentos ativos podem ser reagendados", http.StatusConflict)
+		return
+	}
+
+	// As configurações também montam as mensagens de recusa do proprietário
+	config, err := carregarConfiguracoes(r.Context(), h.DB, salaoID)
+	if err != nil {
+		log.Printf("Erro ao buscar configurações do salão %d: %v", salaoID, err)
 		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
 		return
 	}
 
-	count, err := res.RowsAffected()
+	// Quando é o cliente quem reagenda, vale também a política de cancelamento
+	if aplicarPolitica {
+		if agenda.DentroDoPrazoMinimo(agendamento.DataHoraInicio, config.AntecedenciaMinimaCancelamentoHoras, h.Relogio.Agora()) {
+			http.Error(w, fmt.Sprintf("Prazo para reagendamento encerrado: reagendamentos exigem %d hora(s) de antecedência", config.AntecedenciaMinimaCancelamentoHoras), http.StatusUnprocessableEntity)
+			return
+		}
+		if agendamento.Reagendamentos >= config.MaxReagendamentos {
+			http.Error(w, fmt.Sprintf("Limite de %d reagendamento(s) atingido para este agendamento", config.MaxReagendamentos), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	servicos, err := buscarServicosDoAgendamento(r.Context(), tx, agendamentoID)
 	if err != nil {
-		log.Printf("Erro ao verificar linhas afetadas: %v", err)
+		log.Printf("Erro ao buscar serviços do agendamento %d: %v", agendamentoID, err)
 		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
 		return
 	}
-	if count == 0 {
-		http.Error(w, "Agendamento não encontrado", http.StatusNotFound)
+
+	// A visita é planejada de novo pelo mesmo motor da criação, com os mesmos
+	// serviços e profissionais: só é aceito um horário que a disponibilidade
+	// ofereceria, sem contar o próprio agendamento como ocupado
+	etapas := make([]etapaPedida, 0, len(servicos))
+	for _, item := range servicos {
+		servico, err := buscarServico(r.Context(), h.DB, salaoID, item.ServicoID)
+		if err != nil {
+			log.Printf("Erro ao buscar serviço %d: %v", item.ServicoID, err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			return
+		}
+		if !servico.Ativo {
+			http.Error(w, fmt.Sprintf("O serviço %s não é mais oferecido pelo salão", servico.Nome), http.StatusUnprocessableEntity)
+			return
+		}
+		etapas = append(etapas, etapaPedida{Servico: servico, FuncionarioID: item.FuncionarioID})
+	}
+
+	cal, err := carregarCalendario(r.Context(), h.DB, salaoID)
+	if err != nil {
+		log.Printf("Erro ao carregar expediente do salão %d: %v", salaoID, err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	dia := disponibilidade.MeiaNoite(novoInicio.In(cal.Loc), cal.Loc)
+	consulta, err := carregarConsulta(r.Context(), h.DB, cal, salaoID, etapas, dia, dia, h.Relogio.Agora(), agendamentoID)
+	if errors.Is(err, errFuncionarioNaoEncontrado) {
+		http.Error(w, "O profissional do agendamento não atende mais no salão", http.StatusUnprocessableEntity)
 		return
 	}
+	if err != nil {
+		log.Printf("Erro ao carregar disponibilidade do salão %d: %v", salaoID, err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	if !consulta.SemFuncionarios {
+		for i, etapa := range consulta.Etapas {
+			if len(etapa.Funcionarios) > 0 {
+				continue
+			}
+			if servicos[i].FuncionarioID != nil {
+				http.Error(w, fmt.Sprintf("O profissional não realiza mais o serviço %s", etapa.Servico.Nome), http.StatusUnprocessableEntity)
+			} else {
+				http.Error(w, fmt.Sprintf("Nenhum profissional realiza o serviço %s", etapa.Servico.Nome), http.StatusUnprocessableEntity)
+			}
+			return
+		}
+	}
+	plano, recusa := consulta.Planejar(novoInicio)
+	if recusa != nil {
+		responderRecusa(w, recusa, config, true)
+		return
+	}
+
+	// O preço continua o combinado na reserva
+	inicioAnterior := agendamento.DataHoraInicio
+	precos := make([]float64, len(servicos))
+	for i, item := range servicos {
+		precos[i] = item.Preco
+	}
+	aplicarPlano(&agendamento, plano)
+	servicos = agendamento.Servicos
+	agendamento.PrecoTotal = 0
+	for i := range servicos {
+		servicos[i].Preco = precos[i]
+		agendamento.PrecoTotal += precos[i]
+	}
 
-	log.Printf("!!! GATILHO N8N: Agendamento ID %d foi atualizado para %s. Notificar cliente final!", agendamentoID, novoStatus)
+	_, err = tx.ExecContext(r.Context(), `
+		UPDATE agendamentos SET funcionario_id = $1, data_hora_inicio = $2, data_hora_fim = $3,
+		       bloqueio_inicio = $4, bloqueio_fim = $5, reagendamentos = reagendamentos + 1
+		WHERE id = $6`,
+		agendamento.FuncionarioID, agendamento.DataHoraInicio, agendamento.DataHoraFim,
+		agendamento.BloqueioInicio, agendamento.BloqueioFim, agendamentoID)
+	if err == nil {
+		// Todos os serviços mudam no mesmo comando, para não esbarrarem uns nos outros
+		posicoes, funcionarios := make([]int, len(servicos)), make([]int, len(servicos))
+		inicios, fins := make([]time.Time, len(servicos)), make([]time.Time, len(servicos))
+		bloqueiosInicio, bloqueiosFim := make([]time.Time, len(servicos)), make([]time.Time, len(servicos))
+		for i, item := range servicos {
+			posicoes[i] = item.Posicao
+			if item.FuncionarioID != nil {
+				funcionarios[i] = *item.FuncionarioID
+			}
+			inicios[i], fins[i] = item.DataHoraInicio, item.DataHoraFim
+			bloqueiosInicio[i], bloqueiosFim[i] = item.BloqueioInicio, item.BloqueioFim
+		}
+		_, err = tx.ExecContext(r.Context(), `
+			UPDATE agendamento_servicos ags SET funcionario_id = NULLIF(n.funcionario_id, 0),
+			       data_hora_inicio = n.inicio, data_hora_fim = n.fim,
+			       bloqueio_inicio = n.bloqueio_inicio, bloqueio_fim = n.bloqueio_fim
+			FROM unnest($2::int[], $3::int[], $4::timestamptz[], $5::timestamptz[], $6::timestamptz[], $7::timestamptz[])
+			     AS n(posicao, funcionario_id, inicio, fim, bloqueio_inicio, bloqueio_fim)
+			WHERE ags.agendamento_id = $1 AND ags.posicao = n.posicao`,
+			agendamentoID, posicoes, funcionarios, inicios, fins, bloqueiosInicio, bloqueiosFim)
+	}
+	if ehConflitoDeHorario(err) {
+		tx.Rollback()
+		conflito, errConflito := buscarConflitoDaVisita(r.Context(), h.DB, salaoID, servicos, agendamentoID)
+		if errConflito != nil {
+			responderConflito(w, nil)
+			return
+		}
+		responderConflito(w, &conflito)
+		return
+	}
+	if err != nil {
+		log.Printf("Erro ao reagendar: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
 
+	motivo := "Reagendado de " + inicioAnterior.In(cal.Loc).Format("15:04 de 02/01/2006")
+	if err := agenda.RegistrarHistorico(r.Context(), tx, agendamentoID, &agendamento.Status, agendamento.Status, motivo, autor); err != nil {
+		log.Printf("Erro ao gravar histórico: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	if err := h.Notificador.NotificarAgendamento(r.Context(), tx, webhooks.EventoAgendamentoReagendado, agendamentoID, &inicioAnterior); err != nil {
+		log.Printf("Erro ao enfileirar notificação: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	if err := agenda.AgendarLembretes(r.Context(), tx, agendamentoID); err != nil {
+		log.Printf("Erro ao agendar lembretes: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Erro ao confirmar transação: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	agendamento.Reagendamentos++
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "sucesso", "novo_status": novoStatus})
+	json.NewEncoder(w).Encode(agendamento)
 }
 
-func (h *AgendamentosHandler) ConfirmarAgendamento(w http.ResponseWriter, r *http.Request) {
-	h.updateAgendamentoStatus(w, r, "CONFIRMADO")
-}
+// buscarServicosDoAgendamento lê os serviços do agendamento na ordem da
+// visita, travando-os até o fim da transação.
+func buscarServicosDoAgendamento(ctx context.Context, tx *sql.Tx, agendamentoID int) ([]models.AgendamentoServico, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT ags.posicao, ags.servico_id, s.nome, ags.funcionario_id, ags.data_hora_inicio, ags.data_hora_fim,
+		       ags.bloqueio_inicio, ags.bloqueio_fim, ags.preco
+		FROM agendamento_servicos ags
+		JOIN servicos s ON s.id = ags.servico_id
+		WHERE ags.agendamento_id = $1
+		ORDER BY ags.posicao
+		FOR UPDATE OF ags`,
+		agendamentoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-func (h *AgendamentosHandler) CancelarAgendamento(w http.ResponseWriter, r *http.Request) {
-	h.updateAgendamentoStatus(w, r, "CANCELADO")
+	var servicos []models.AgendamentoServico
+	for rows.Next() {
+		var item models.AgendamentoServico
+		if err := rows.Scan(&item.Posicao, &item.ServicoID, &item.ServicoNome, &item.FuncionarioID, &item.DataHoraInicio, &item.DataHoraFim,
+			&item.BloqueioInicio, &item.BloqueioFim, &item.Preco); err != nil {
+			return nil, err
+		}
+		servicos = append(servicos, item)
+	}
+	return servicos, rows.Err()
 }
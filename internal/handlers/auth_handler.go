@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/emaildoissa/agenda-flow/internal/auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AuthHandler cuida do login do proprietário e da renovação de tokens.
+type AuthHandler struct {
+	DB     *sql.DB
+	Tokens *auth.GerenciadorTokens
+}
+
+// NewAuthHandler cria uma nova instância de AuthHandler.
+func NewAuthHandler(db *sql.DB, tokens *auth.GerenciadorTokens) *AuthHandler {
+	return &AuthHandler{DB: db, Tokens: tokens}
+}
+
+type loginRequest struct {
+	Email string `json:"email"`
+	Senha string `json:"senha"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Login verifica o e-mail e a senha do proprietário e emite os tokens.
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+		return
+	}
+	if req.Email == "" || req.Senha == "" {
+		http.Error(w, "E-mail e senha são obrigatórios", http.StatusBadRequest)
+		return
+	}
+
+	var salaoID int
+	var hashSenha string
+	err = h.DB.QueryRowContext(r.Context(), "SELECT id, hash_senha FROM saloes WHERE email_proprietario = $1", req.Email).Scan(&salaoID, &hashSenha)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Credenciais inválidas", http.StatusUnauthorized)
+		} else {
+			log.Printf("Erro ao buscar salão para login: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hashSenha), []byte(req.Senha)) != nil {
+		http.Error(w, "Credenciais inválidas", http.StatusUnauthorized)
+		return
+	}
+
+	h.responderTokens(w, salaoID)
+}
+
+// Refresh troca um refresh token válido por um novo par de tokens.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.RefreshToken == "" {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := h.Tokens.Validar(req.RefreshToken, auth.TipoRefresh)
+	if err != nil {
+		http.Error(w, "Refresh token inválido ou expirado", http.StatusUnauthorized)
+		return
+	}
+
+	// Garante que o salão ainda existe antes de renovar a sessão.
+	var existe bool
+	err = h.DB.QueryRowContext(r.Context(), "SELECT EXISTS (SELECT 1 FROM saloes WHERE id = $1)", claims.SalaoID).Scan(&existe)
+	if err != nil {
+		log.Printf("Erro ao verificar salão no refresh: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	if !existe {
+		http.Error(w, "Refresh token inválido ou expirado", http.StatusUnauthorized)
+		return
+	}
+
+	h.responderTokens(w, claims.SalaoID)
+}
+
+func (h *AuthHandler) responderTokens(w http.ResponseWriter, salaoID int) {
+	tokens, err := h.Tokens.GerarPar(salaoID)
+	if err != nil {
+		log.Printf("Erro ao gerar tokens: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tokens)
+}
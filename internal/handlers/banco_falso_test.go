@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// bancoFalso é um banco vazio para testar os handlers sem PostgreSQL: toda
+// consulta volta sem linhas e todo comando sem linhas afetadas, como quando o
+// registro pedido não existe no salão. Os comandos executados ficam gravados
+// para o teste conferir por qual salão cada um filtrou. Com responder, as
+// consultas que contêm um trecho passam a devolver as linhas informadas.
+type bancoFalso struct {
+	mu        sync.Mutex
+	comandos  []comandoFalso
+	respostas []respostaFalsa
+}
+
+type respostaFalsa struct {
+	trecho  string
+	colunas []string
+	linhas  [][]driver.Value
+}
+
+type comandoFalso struct {
+	sql  string
+	args []any
+}
+
+func novoBancoFalso() (*sql.DB, *bancoFalso) {
+	b := &bancoFalso{}
+	return sql.OpenDB(b), b
+}
+
+func (b *bancoFalso) registrar(query string, args []driver.NamedValue) {
+	c := comandoFalso{sql: query}
+	for _, a := range args {
+		c.args = append(c.args, a.Value)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.comandos = append(b.comandos, c)
+}
+
+// responder faz as consultas que contêm trecho devolverem sempre as linhas
+// dadas; a primeira resposta cadastrada que combinar é a usada.
+func (b *bancoFalso) responder(trecho string, colunas []string, linhas ...[]driver.Value) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.respostas = append(b.respostas, respostaFalsa{trecho: trecho, colunas: colunas, linhas: linhas})
+}
+
+func (b *bancoFalso) resposta(query string) (*linhasFalsas, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, r := range b.respostas {
+		if strings.Contains(query, r.trecho) {
+			return &linhasFalsas{colunas: r.colunas, valores: append([][]driver.Value(nil), r.linhas...)}, true
+		}
+	}
+	return nil, false
+}
+
+func (b *bancoFalso) executados() []comandoFalso {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]comandoFalso(nil), b.comandos...)
+}
+
+// driver.Connector
+func (b *bancoFalso) Connect(context.Context) (driver.Conn, error) { return conexaoFalsa{b}, nil }
+func (b *bancoFalso) Driver() driver.Driver                        { return nil }
+
+type conexaoFalsa struct{ banco *bancoFalso }
+
+func (c conexaoFalsa) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("bancoFalso: Prepare não suportado")
+}
+func (c conexaoFalsa) Close() error              { return nil }
+func (c conexaoFalsa) Begin() (driver.Tx, error) { return transacaoFalsa{}, nil }
+
+// CheckNamedValue aceita qualquer argumento, como o pgx aceita listas e JSON.
+func (c conexaoFalsa) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c conexaoFalsa) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.banco.registrar(query, args)
+	if linhas, ok := c.banco.resposta(query); ok {
+		return linhas, nil
+	}
+	// SELECT EXISTS sempre devolve uma linha
+	if strings.Contains(query, "SELECT EXISTS") {
+		return &linhasFalsas{colunas: []string{"exists"}, valores: [][]driver.Value{{false}}}, nil
+	}
+	return &linhasFalsas{}, nil
+}
+
+func (c conexaoFalsa) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.banco.registrar(query, args)
+	return driver.RowsAffected(0), nil
+}
+
+type transacaoFalsa struct{}
+
+func (transacaoFalsa) Commit() error   { return nil }
+func (transacaoFalsa) Rollback() error { return nil }
+
+type linhasFalsas struct {
+	colunas []string
+	valores [][]driver.Value
+}
+
+func (l *linhasFalsas) Columns() []string { return l.colunas }
+func (l *linhasFalsas) Close() error      { return nil }
+func (l *linhasFalsas) Next(dest []driver.Value) error {
+	if len(l.valores) == 0 {
+		return io.EOF
+	}
+	copy(dest, l.valores[0])
+	l.valores = l.valores[1:]
+	return nil
+}
+
+// filtraPorSalao diz se o salão está entre os argumentos do comando.
+func (c comandoFalso) filtraPorSalao(salaoID int) bool {
+	for _, a := range c.args {
+		if a == salaoID || a == int64(salaoID) {
+			return true
+		}
+	}
+	return false
+}
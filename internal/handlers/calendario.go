@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/disponibilidade"
+	"github.com/emaildoissa/agenda-flow/internal/models"
+)
+
+// errFuncionarioNaoEncontrado indica que o profissional pedido não é um
+// funcionário ativo do salão.
+var errFuncionarioNaoEncontrado = errors.New("funcionário não encontrado")
+
+// carregarCalendario lê os horários de funcionamento e o fuso do salão.
+// Devolve sql.ErrNoRows se o salão não existir. As exceções precisam ser
+// carregadas depois, para o período desejado, com carregarExcecoes.
+func carregarCalendario(ctx context.Context, db *sql.DB, salaoID int) (*disponibilidade.Calendario, error) {
+	var semanal models.HorarioSemanal
+	var fusoHorario string
+	err := db.QueryRowContext(ctx, "SELECT horarios_funcionamento, fuso_horario FROM saloes WHERE id = $1", salaoID).Scan(&semanal, &fusoHorario)
+	if err != nil {
+		return nil, fmt.Errorf("horários do salão %d: %w", salaoID, err)
+	}
+	return disponibilidade.NovoCalendario(semanal, localizacaoDoSalao(fusoHorario)), nil
+}
+
+// carregarExcecoes lê as exceções do salão e dos seus profissionais entre os
+// dias de e ate, inclusive, tomados no calendário local do salão.
+func carregarExcecoes(ctx context.Context, db *sql.DB, cal *disponibilidade.Calendario, salaoID int, de, ate time.Time) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, salao_id, funcionario_id, to_char(data, 'YYYY-MM-DD'), fechado, horario, descricao
+		FROM excecoes_horario
+		WHERE salao_id = $1 AND data BETWEEN $2::date AND $3::date`,
+		salaoID, de.Format("2006-01-02"), ate.Format("2006-01-02"))
+	if err != nil {
+		return fmt.Errorf("exceções do salão %d: %w", salaoID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := escanearExcecao(rows)
+		if err != nil {
+			return err
+		}
+		cal.AdicionarExcecao(e)
+	}
+	return rows.Err()
+}
+
+// etapaPedida é um dos serviços pedidos para a visita, com o profissional
+// escolhido pelo cliente, se houver.
+type etapaPedida struct {
+	Servico       models.Servico
+	FuncionarioID *int
+}
+
+// erroEtapa indica em qual serviço da visita (a partir de 0) o carregamento falhou.
+type erroEtapa struct {
+	Indice int
+	Err    error
+}
+
+func (e *erroEtapa) Error() string {
+	return fmt.Sprintf("serviço %d da visita: %v", e.Indice+1, e.Err)
+}
+func (e *erroEtapa) Unwrap() error { return e.Err }
+
+// carregarConsulta carrega tudo que o motor de disponibilidade usa para a
+// visita entre os dias de e ate (meias-noites no fuso do salão, inclusivas):
+// exceções, configurações, profissionais e agendamentos. É o mesmo carregamento
+// para listar horários e para validar um agendamento, para que as duas coisas
+// nunca divirjam. Uma etapa com FuncionarioID fica restrita a esse profissional
+// e gera errFuncionarioNaoEncontrado (dentro de um *erroEtapa) se ele não for
+// um funcionário ativo do salão. ignorar é o agendamento que está sendo
+// movido, que não ocupa a agenda (0 para listar ou criar).
+func carregarConsulta(ctx context.Context, db *sql.DB, cal *disponibilidade.Calendario, salaoID int, etapas []etapaPedida, de, ate, agora time.Time, ignorar int) (*disponibilidade.Consulta, error) {
+	if err := carregarExcecoes(ctx, db, cal, salaoID, de, ate); err != nil {
+		return nil, err
+	}
+
+	config, err := carregarConfiguracoes(ctx, db, salaoID)
+	if err != nil {
+		return nil, fmt.Errorf("configurações do salão %d: %w", salaoID, err)
+	}
+	c := disponibilidade.NovaConsulta(cal, config, janelaDoSalao(config, cal.Loc), agora)
+
+	c.Agendamentos, err = buscarAgendamentosDoPeriodo(ctx, db, salaoID, de, disponibilidade.SomarDias(ate, 1), ignorar)
+	if err != nil {
+		return nil, fmt.Errorf("agendamentos do salão %d: %w", salaoID, err)
+	}
+
+	funcionarios, err := buscarFuncionariosAtivos(ctx, db, salaoID)
+	if err != nil {
+		return nil, fmt.Errorf("funcionários do salão %d: %w", salaoID, err)
+	}
+	// Salões sem funcionários cadastrados continuam com uma única agenda
+	c.SemFuncionarios = len(funcionarios) == 0
+
+	for i, pedida := range etapas {
+		etapa := disponibilidade.Etapa{Servico: pedida.Servico}
+		candidatos := funcionarios
+		if pedida.FuncionarioID != nil {
+			candidatos = filtrarFuncionario(funcionarios, *pedida.FuncionarioID)
+			if len(candidatos) == 0 {
+				return nil, &erroEtapa{Indice: i, Err: errFuncionarioNaoEncontrado}
+			}
+		}
+		// Só entram os profissionais que realizam o serviço, cada um com a sua duração
+		if !c.SemFuncionarios {
+			etapa.Funcionarios, etapa.Condicoes, err = profissionaisDoServico(ctx, db, pedida.Servico, candidatos)
+			if err != nil {
+				return nil, fmt.Errorf("profissionais do serviço %d: %w", pedida.Servico.ID, err)
+			}
+		}
+		c.Etapas = append(c.Etapas, etapa)
+	}
+	return c, nil
+}
+
+// buscarAgendamentosDoPeriodo busca numa única consulta os serviços de
+// agendamentos ativos cujo bloqueio (horário mais buffers) cruza o trecho entre
+// inicio e fim. Cada serviço vem como um models.Agendamento com o ID do
+// agendamento e o horário e o profissional do próprio serviço; os serviços do
+// agendamento ignorar ficam de fora.
+func buscarAgendamentosDoPeriodo(ctx context.Context, db *sql.DB, salaoID int, inicio, fim time.Time, ignorar int) ([]models.Agendamento, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT agendamento_id, funcionario_id, data_hora_inicio, data_hora_fim, bloqueio_inicio, bloqueio_fim FROM agendamento_servicos
+		WHERE salao_id = $1 AND bloqueio_inicio < $3 AND bloqueio_fim > $2 AND ativo AND agendamento_id <> $4
+		ORDER BY data_hora_inicio`,
+		salaoID, inicio, fim, ignorar)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var agendamentos []models.Agendamento
+	for rows.Next() {
+		var a models.Agendamento
+		if err := rows.Scan(&a.ID, &a.FuncionarioID, &a.DataHoraInicio, &a.DataHoraFim, &a.BloqueioInicio, &a.BloqueioFim); err != nil {
+			return nil, err
+		}
+		agendamentos = append(agendamentos, a)
+	}
+	return agendamentos, rows.Err()
+}
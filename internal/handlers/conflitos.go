@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/models"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Código SQLSTATE para violação de constraint de exclusão (exclusion_violation)
+const codigoViolacaoExclusao = "23P01"
+
+// ehConflitoDeHorario identifica o erro devolvido pelo Postgres quando um
+// agendamento ativo se sobreporia a outro na mesma agenda.
+func ehConflitoDeHorario(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == codigoViolacaoExclusao
+}
+
+// buscarConflito devolve o serviço agendado cujo bloqueio (horário mais buffers)
+// ocupa parte do trecho informado na agenda do profissional (ou na agenda sem
+// profissional, se funcionarioID for nulo). O ID devolvido é o do agendamento;
+// ignorar é o agendamento que está sendo movido (0 na criação).
+func buscarConflito(ctx context.Context, db *sql.DB, salaoID int, funcionarioID *int, inicio, fim time.Time, ignorar int) (models.Agendamento, error) {
+	var a models.Agendamento
+	err := db.QueryRowContext(ctx, `
+		SELECT agendamento_id, funcionario_id, data_hora_inicio, data_hora_fim FROM agendamento_servicos
+		WHERE salao_id = $1 AND COALESCE(funcionario_id, 0) = COALESCE($2::int, 0)
+		  AND ativo AND bloqueio_inicio < $4 AND bloqueio_fim > $3 AND agendamento_id <> $5
+		ORDER BY data_hora_inicio
+		LIMIT 1`,
+		salaoID, funcionarioID, inicio, fim, ignorar).Scan(&a.ID, &a.FuncionarioID, &a.DataHoraInicio, &a.DataHoraFim)
+	return a, err
+}
+
+// buscarConflitoDaVisita procura, serviço a serviço, o que impede a visita.
+// Devolve sql.ErrNoRows se nenhum serviço esbarrar em outro agendamento.
+func buscarConflitoDaVisita(ctx context.Context, db *sql.DB, salaoID int, servicos []models.AgendamentoServico, ignorar int) (models.Agendamento, error) {
+	for _, s := range servicos {
+		conflito, err := buscarConflito(ctx, db, salaoID, s.FuncionarioID, s.BloqueioInicio, s.BloqueioFim, ignorar)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return conflito, err
+		}
+	}
+	return models.Agendamento{}, sql.ErrNoRows
+}
+
+type horarioConflitante struct {
+	DataHoraInicio time.Time `json:"data_hora_inicio"`
+	DataHoraFim    time.Time `json:"data_hora_fim"`
+}
+
+type respostaConflito struct {
+	Erro     string              `json:"erro"`
+	Conflito *horarioConflitante `json:"conflito,omitempty"`
+}
+
+// responderConflito devolve 409 Conflict com o horário que já está ocupado.
+func responderConflito(w http.ResponseWriter, conflito *models.Agendamento) {
+	resposta := respostaConflito{Erro: "Horário indisponível: já existe um agendamento neste intervalo"}
+	if conflito != nil {
+		resposta.Conflito = &horarioConflitante{
+			DataHoraInicio: conflito.DataHoraInicio,
+			DataHoraFim:    conflito.DataHoraFim,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(resposta)
+}
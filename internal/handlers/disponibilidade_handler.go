@@ -3,160 +3,200 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/emaildoissa/agenda-flow/internal/agenda"
+	"github.com/emaildoissa/agenda-flow/internal/disponibilidade"
 	"github.com/emaildoissa/agenda-flow/internal/models"
-	"github.com/go-chi/chi/v5"
 )
 
-// Estruturas para decodificar o JSON de horários
-type HorarioDia struct {
-	Inicio string  `json:"inicio"`
-	Fim    string  `json:"fim"`
-	Pausas []Pausa `json:"pausas"`
-}
-type Pausa struct {
-	Inicio string `json:"inicio"`
-	Fim    string `json:"fim"`
-}
-
 type DisponibilidadeHandler struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Relogio agenda.Relogio // Hora atual usada na janela de reservas
 }
 
 func NewDisponibilidadeHandler(db *sql.DB) *DisponibilidadeHandler {
-	return &DisponibilidadeHandler{DB: db}
+	return &DisponibilidadeHandler{DB: db, Relogio: agenda.RelogioDoSistema{}}
 }
 
+// maxDiasPeriodo limita o tamanho do período consultado de uma só vez.
+const maxDiasPeriodo = 62
+
 func (h *DisponibilidadeHandler) GetDisponibilidade(w http.ResponseWriter, r *http.Request) {
-	salaoIDStr := chi.URLParam(r, "idSalao")
-	salaoID, _ := strconv.Atoi(salaoIDStr)
 	dataStr := r.URL.Query().Get("data")
-	servicoIDStr := r.URL.Query().Get("servicoId")
-	servicoID, _ := strconv.Atoi(servicoIDStr)
-
-	data, err := time.ParseInLocation("2006-01-02", dataStr, time.UTC)
+	dataInformada, err := time.Parse("2006-01-02", dataStr)
 	if err != nil {
 		http.Error(w, "Formato de data inválido. Use YYYY-MM-DD", http.StatusBadRequest)
 		return
 	}
 
-	var duracaoMinutos int
-	err = h.DB.QueryRow("SELECT duracao_minutos FROM servicos WHERE id = $1", servicoID).Scan(&duracaoMinutos)
-	if err != nil {
-		http.Error(w, "Serviço não encontrado", http.StatusNotFound)
+	c, ok := h.prepararConsulta(w, r, dataInformada, dataInformada)
+	if !ok {
 		return
 	}
+	porFuncionario := r.URL.Query().Get("porFuncionario") == "true"
+	dia := c.DoDia(disponibilidade.MeiaNoite(dataInformada, c.Calendario.Loc), porFuncionario && !c.SemFuncionarios)
 
-	var horariosJSON []byte
-	err = h.DB.QueryRow("SELECT horarios_funcionamento FROM saloes WHERE id = $1", salaoID).Scan(&horariosJSON)
-	if err != nil || horariosJSON == nil {
-		http.Error(w, "Horários de funcionamento não configurados", http.StatusNotFound)
-		return
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case c.SemFuncionarios:
+		json.NewEncoder(w).Encode(dia.Slots)
+	case porFuncionario:
+		json.NewEncoder(w).Encode(dia.Funcionarios)
+	default:
+		// Sem agrupamento, devolvemos os horários em que ao menos um profissional está livre
+		json.NewEncoder(w).Encode(dia.Slots)
 	}
+}
 
-	var todosHorarios map[string]*HorarioDia
-	json.Unmarshal(horariosJSON, &todosHorarios)
-	agendamentos, err := h.getAgendamentosDoDia(salaoID, data)
-	if err != nil {
-		http.Error(w, "Erro ao buscar agendamentos", http.StatusInternalServerError)
+// GetDisponibilidadePeriodo devolve os horários livres de cada dia entre de e
+// ate (inclusivos), agrupados por dia e, com porFuncionario=true, também por
+// profissional. Com somenteDias=true devolve só as datas que têm algum horário
+// livre, para o calendário marcar os dias disponíveis.
+func (h *DisponibilidadeHandler) GetDisponibilidadePeriodo(w http.ResponseWriter, r *http.Request) {
+	de, errDe := time.Parse("2006-01-02", r.URL.Query().Get("de"))
+	ate, errAte := time.Parse("2006-01-02", r.URL.Query().Get("ate"))
+	if errDe != nil || errAte != nil {
+		http.Error(w, "Informe 'de' e 'ate' no formato YYYY-MM-DD", http.StatusBadRequest)
 		return
 	}
-	log.Printf("Para a data %s, foram encontrados %d agendamentos.", dataStr, len(agendamentos))
-
-	diaDaSemanaIngles := strings.ToLower(data.Weekday().String())
-	mapaDias := map[string]string{
-		"sunday": "domingo", "monday": "segunda", "tuesday": "terca",
-		"wednesday": "quarta", "thursday": "quinta", "friday": "sexta",
-		"saturday": "sabado",
+	if ate.Before(de) {
+		http.Error(w, "'ate' não pode ser anterior a 'de'", http.StatusBadRequest)
+		return
 	}
-	chaveDiaPt := mapaDias[diaDaSemanaIngles]
-	horarioDoDia := todosHorarios[chaveDiaPt]
+	if ate.Sub(de) >= maxDiasPeriodo*24*time.Hour {
+		http.Error(w, fmt.Sprintf("O período pode ter no máximo %d dias", maxDiasPeriodo), http.StatusBadRequest)
+		return
+	}
+	porFuncionario := r.URL.Query().Get("porFuncionario") == "true"
+	somenteDias := r.URL.Query().Get("somenteDias") == "true"
 
-	if horarioDoDia == nil {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode([]string{})
+	c, ok := h.prepararConsulta(w, r, de, ate)
+	if !ok {
 		return
 	}
 
-	slotsDisponiveis := h.calcularSlots(data, horarioDoDia, duracaoMinutos, agendamentos)
+	dias := []disponibilidade.Dia{}
+	datas := []string{}
+	inicio := disponibilidade.MeiaNoite(de, c.Calendario.Loc)
+	fim := disponibilidade.MeiaNoite(ate, c.Calendario.Loc)
+	for data := inicio; !data.After(fim); data = disponibilidade.SomarDias(data, 1) {
+		dia := c.DoDia(data, porFuncionario && !c.SemFuncionarios)
+		if somenteDias {
+			if len(dia.Slots) > 0 {
+				datas = append(datas, dia.Data)
+			}
+			continue
+		}
+		dias = append(dias, dia)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(slotsDisponiveis)
+	if somenteDias {
+		json.NewEncoder(w).Encode(datas)
+		return
+	}
+	json.NewEncoder(w).Encode(dias)
 }
 
-func (h *DisponibilidadeHandler) getAgendamentosDoDia(salaoID int, data time.Time) ([]models.Agendamento, error) {
-	inicioDia := time.Date(data.Year(), data.Month(), data.Day(), 0, 0, 0, 0, time.UTC)
-	fimDia := inicioDia.Add(24 * time.Hour)
+// maxServicosVisita limita quantos serviços podem ser feitos na mesma visita.
+const maxServicosVisita = 5
 
-	rows, err := h.DB.Query(`
-		SELECT id, data_hora_inicio, data_hora_fim, status FROM agendamentos
-		WHERE salao_id = $1 AND data_hora_inicio >= $2 AND data_hora_inicio < $3 AND status IN ('CONFIRMADO', 'PENDENTE')`,
-		salaoID, inicioDia, fimDia)
+// prepararConsulta lê os parâmetros comuns (servicoId ou servicoIds,
+// funcionarioId) e carrega de uma vez o calendário, os profissionais e os
+// agendamentos dos dias de a ate. Se algo falhar, já responde a requisição e
+// devolve ok falso.
+func (h *DisponibilidadeHandler) prepararConsulta(w http.ResponseWriter, r *http.Request, de, ate time.Time) (*disponibilidade.Consulta, bool) {
+	salaoID, err := salaoDoEscopo(r)
 	if err != nil {
-		return nil, err
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
 	}
-	defer rows.Close()
-
-	var agendamentos []models.Agendamento
-	for rows.Next() {
-		var a models.Agendamento
-		if err := rows.Scan(&a.ID, &a.DataHoraInicio, &a.DataHoraFim, &a.Status); err != nil {
-			return nil, err
+	// servicoIds=1,2 pede uma visita com vários serviços, feitos nessa ordem
+	idsStr := r.URL.Query().Get("servicoIds")
+	if idsStr == "" {
+		idsStr = r.URL.Query().Get("servicoId")
+	}
+	var servicoIDs []int
+	for _, idStr := range strings.Split(idsStr, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(idStr))
+		if err != nil {
+			http.Error(w, "ID de serviço inválido", http.StatusBadRequest)
+			return nil, false
 		}
-		agendamentos = append(agendamentos, a)
+		servicoIDs = append(servicoIDs, id)
 	}
-	return agendamentos, nil
-}
-
-func (h *DisponibilidadeHandler) calcularSlots(dataDoAgendamento time.Time, horarioUtil *HorarioDia, duracaoServico int, agendamentosExistentes []models.Agendamento) []string {
-	var slotsDisponiveis []string
-	loc := time.UTC
-
-	parseTime := func(t string) time.Time {
-		parsed, _ := time.Parse("15:04", t)
-		return time.Date(dataDoAgendamento.Year(), dataDoAgendamento.Month(), dataDoAgendamento.Day(), parsed.Hour(), parsed.Minute(), 0, 0, loc)
+	if len(servicoIDs) > maxServicosVisita {
+		http.Error(w, fmt.Sprintf("Informe no máximo %d serviços por visita", maxServicosVisita), http.StatusBadRequest)
+		return nil, false
 	}
 
-	inicioDia := parseTime(horarioUtil.Inicio)
-	fimDia := parseTime(horarioUtil.Fim)
-
-	slotAtual := inicioDia
-	for slotAtual.Before(fimDia) {
-		fimSlot := slotAtual.Add(time.Duration(duracaoServico) * time.Minute)
-		if fimSlot.After(fimDia) {
-			break
+	// funcionarioId restringe a um profissional, que faz todos os serviços
+	var funcionarioID *int
+	if funcionarioIDStr := r.URL.Query().Get("funcionarioId"); funcionarioIDStr != "" {
+		id, err := strconv.Atoi(funcionarioIDStr)
+		if err != nil {
+			http.Error(w, "ID de funcionário inválido", http.StatusBadRequest)
+			return nil, false
 		}
+		funcionarioID = &id
+	}
 
-		temConflito := false
-
-		// VERIFICA CONFLITO COM AGENDAMENTOS EXISTENTES
-		for _, agendamento := range agendamentosExistentes {
-			// <<< LOG DE DEPURAÇÃO ADICIONADO AQUI >>>
-			log.Printf("--- Checando Slot: %s ---", slotAtual.Format("15:04"))
-			log.Printf("SLOT INÍCIO: %s", slotAtual.Format(time.RFC3339))
-			log.Printf("SLOT FIM:    %s", fimSlot.Format(time.RFC3339))
-			log.Printf("AGENDADO INÍCIO: %s", agendamento.DataHoraInicio.Format(time.RFC3339))
-			log.Printf("AGENDADO FIM:    %s", agendamento.DataHoraFim.Format(time.RFC3339))
-
-			if slotAtual.Before(agendamento.DataHoraFim) && fimSlot.After(agendamento.DataHoraInicio) {
-				log.Printf("--> CONFLITO DETECTADO!")
-				temConflito = true
-				break
-			}
+	etapas := make([]etapaPedida, 0, len(servicoIDs))
+	for _, servicoID := range servicoIDs {
+		servico, err := buscarServico(r.Context(), h.DB, salaoID, servicoID)
+		if err != nil || !servico.Ativo {
+			http.Error(w, "Serviço não encontrado", http.StatusNotFound)
+			return nil, false
 		}
+		etapas = append(etapas, etapaPedida{Servico: servico, FuncionarioID: funcionarioID})
+	}
 
-		// VERIFICA CONFLITO COM PAUSAS (lógica omitida para simplificar, mas a estrutura é a mesma)
+	cal, err := carregarCalendario(r.Context(), h.DB, salaoID)
+	if err != nil || cal.Semanal == nil {
+		http.Error(w, "Horários de funcionamento não configurados", http.StatusNotFound)
+		return nil, false
+	}
 
-		if !temConflito {
-			slotsDisponiveis = append(slotsDisponiveis, slotAtual.Format("15:04"))
-		}
+	// As datas pedidas são dias do calendário local do salão; feriados e folgas
+	// cadastrados para elas substituem o expediente semanal
+	inicio := disponibilidade.MeiaNoite(de, cal.Loc)
+	fim := disponibilidade.MeiaNoite(ate, cal.Loc)
+	c, err := carregarConsulta(r.Context(), h.DB, cal, salaoID, etapas, inicio, fim, h.Relogio.Agora(), 0)
+	if errors.Is(err, errFuncionarioNaoEncontrado) {
+		http.Error(w, "Funcionário não encontrado", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Erro ao carregar disponibilidade: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return nil, false
+	}
+	return c, true
+}
 
-		slotAtual = slotAtual.Add(15 * time.Minute)
+// localizacaoDoSalao carrega o fuso IANA do salão. Como o valor é validado ao
+// salvar, uma falha aqui só acontece com dados antigos; usamos o fuso padrão.
+func localizacaoDoSalao(fusoHorario string) *time.Location {
+	loc, err := time.LoadLocation(fusoHorario)
+	if err != nil {
+		log.Printf("Fuso horário inválido '%s', usando %s: %v", fusoHorario, fusoHorarioPadrao, err)
+		loc, _ = time.LoadLocation(fusoHorarioPadrao)
 	}
+	return loc
+}
 
-	return slotsDisponiveis
+func filtrarFuncionario(funcionarios []models.Funcionario, funcionarioID int) []models.Funcionario {
+	for _, f := range funcionarios {
+		if f.ID == funcionarioID {
+			return []models.Funcionario{f}
+		}
+	}
+	return nil
 }
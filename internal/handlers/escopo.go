@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/emaildoissa/agenda-flow/internal/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+var errSalaoInvalido = errors.New("ID de salão inválido")
+
+// salaoDoEscopo devolve o salão ao qual a requisição está restrita.
+// Em rotas autenticadas vale sempre o salão do token; nas rotas públicas,
+// o {idSalao} da URL. Toda consulta dos handlers deve filtrar por ele,
+// e IDs de outro salão devem resultar em 404.
+func salaoDoEscopo(r *http.Request) (int, error) {
+	if salaoID, ok := auth.SalaoIDDoContexto(r.Context()); ok {
+		return salaoID, nil
+	}
+	salaoID, err := strconv.Atoi(chi.URLParam(r, "idSalao"))
+	if err != nil || salaoID <= 0 {
+		return 0, errSalaoInvalido
+	}
+	return salaoID, nil
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/feriados"
+	"github.com/emaildoissa/agenda-flow/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// ExcecoesHandler gerencia feriados, folgas e horários especiais por data.
+// Agendamentos já marcados num dia que passa a ser fechado não são alterados;
+// o salão decide se cancela ou reagenda cada um.
+type ExcecoesHandler struct {
+	DB *sql.DB
+}
+
+func NewExcecoesHandler(db *sql.DB) *ExcecoesHandler {
+	return &ExcecoesHandler{DB: db}
+}
+
+// ListExcecoes lista as exceções do salão e dos profissionais, opcionalmente
+// num período (de/ate, inclusivos) e para um único profissional (funcionarioId).
+func (h *ExcecoesHandler) ListExcecoes(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	de, ok := parametroData(r, "de")
+	if !ok {
+		http.Error(w, "Parâmetro 'de' inválido. Use o formato AAAA-MM-DD", http.StatusBadRequest)
+		return
+	}
+	ate, ok := parametroData(r, "ate")
+	if !ok {
+		http.Error(w, "Parâmetro 'ate' inválido. Use o formato AAAA-MM-DD", http.StatusBadRequest)
+		return
+	}
+	var funcionarioID any
+	if s := r.URL.Query().Get("funcionarioId"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, "ID de funcionário inválido", http.StatusBadRequest)
+			return
+		}
+		funcionarioID = id
+	}
+
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT id, salao_id, funcionario_id, to_char(data, 'YYYY-MM-DD'), fechado, horario, descricao
+		FROM excecoes_horario
+		WHERE salao_id = $1
+		  AND ($2::date IS NULL OR data >= $2::date)
+		  AND ($3::date IS NULL OR data <= $3::date)
+		  AND ($4::int IS NULL OR funcionario_id = $4::int)
+		ORDER BY data, funcionario_id NULLS FIRST`,
+		salaoID, de, ate, funcionarioID)
+	if err != nil {
+		log.Printf("Erro ao buscar exceções de horário: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	excecoes := []models.ExcecaoHorario{}
+	for rows.Next() {
+		e, err := escanearExcecao(rows)
+		if err != nil {
+			log.Printf("Erro ao escanear exceção de horário: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			return
+		}
+		excecoes = append(excecoes, e)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Erro ao buscar exceções de horário: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(excecoes)
+}
+
+// SalvarExcecao cria a exceção da data, ou substitui a que já existir para o
+// mesmo dia e profissional.
+func (h *ExcecoesHandler) SalvarExcecao(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var e models.ExcecaoHorario
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+		return
+	}
+	if msg := validarExcecao(e); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if e.Fechado {
+		e.Horario = nil
+	}
+
+	if e.FuncionarioID != nil {
+		if _, err := buscarFuncionario(r.Context(), h.DB, salaoID, *e.FuncionarioID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Funcionário inválido", http.StatusBadRequest)
+			} else {
+				log.Printf("Erro ao buscar funcionário: %v", err)
+				http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
+	e.SalaoID = salaoID
+	err = h.DB.QueryRowContext(r.Context(), `
+		INSERT INTO excecoes_horario (salao_id, funcionario_id, data, fechado, horario, descricao)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (salao_id, (COALESCE(funcionario_id, 0)), data)
+		DO UPDATE SET fechado = EXCLUDED.fechado, horario = EXCLUDED.horario, descricao = EXCLUDED.descricao
+		RETURNING id`,
+		salaoID, e.FuncionarioID, e.Data, e.Fechado, e.Horario, e.Descricao).Scan(&e.ID)
+	if err != nil {
+		log.Printf("Erro ao salvar exceção de horário: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(e)
+}
+
+// DeleteExcecao remove a exceção; a data volta a seguir o expediente semanal.
+func (h *ExcecoesHandler) DeleteExcecao(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	excecaoID, err := strconv.Atoi(chi.URLParam(r, "idExcecao"))
+	if err != nil {
+		http.Error(w, "ID de exceção inválido", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.DB.ExecContext(r.Context(), "DELETE FROM excecoes_horario WHERE id = $1 AND salao_id = $2", excecaoID, salaoID)
+	if err != nil {
+		log.Printf("Erro ao remover exceção de horário: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Exceção não encontrada", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ImportarFeriados fecha o salão nos feriados nacionais do ano informado em
+// ?ano=. Datas que já têm uma exceção do salão são mantidas como estão, então
+// a importação pode ser repetida sem desfazer ajustes manuais.
+func (h *ExcecoesHandler) ImportarFeriados(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ano, err := strconv.Atoi(r.URL.Query().Get("ano"))
+	if err != nil {
+		http.Error(w, "Informe o ano no parâmetro 'ano'", http.StatusBadRequest)
+		return
+	}
+	lista, ok := feriados.Nacionais(ano)
+	if !ok {
+		http.Error(w, "Não há calendário de feriados para o ano "+strconv.Itoa(ano), http.StatusUnprocessableEntity)
+		return
+	}
+
+	tx, err := h.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Erro ao iniciar transação: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	importados := []models.ExcecaoHorario{}
+	for _, f := range lista {
+		e := models.ExcecaoHorario{SalaoID: salaoID, Data: f.Data, Fechado: true, Descricao: f.Nome}
+		err := tx.QueryRowContext(r.Context(), `
+			INSERT INTO excecoes_horario (salao_id, data, fechado, descricao)
+			VALUES ($1, $2, TRUE, $3)
+			ON CONFLICT (salao_id, (COALESCE(funcionario_id, 0)), data) DO NOTHING
+			RETURNING id`,
+			salaoID, f.Data, f.Nome).Scan(&e.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue // Já existia uma exceção do salão nesta data
+		}
+		if err != nil {
+			log.Printf("Erro ao importar feriado %s: %v", f.Data, err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			return
+		}
+		importados = append(importados, e)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Erro ao confirmar transação: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(importados)
+}
+
+// validarExcecao devolve a mensagem de erro, ou "" se estiver tudo certo.
+func validarExcecao(e models.ExcecaoHorario) string {
+	if _, err := time.Parse("2006-01-02", e.Data); err != nil {
+		return "Data inválida. Use o formato AAAA-MM-DD"
+	}
+	if !e.Fechado && e.Horario == nil {
+		return "Informe 'fechado': true ou o 'horario' especial do dia"
+	}
+	if !e.Fechado {
+		if msg := validarHorarioDia(e.Data, e.Horario); msg != "" {
+			return msg
+		}
+	}
+	if len(strings.TrimSpace(e.Descricao)) > 255 {
+		return "A descrição deve ter no máximo 255 caracteres"
+	}
+	return ""
+}
+
+func escanearExcecao(row interface{ Scan(...any) error }) (models.ExcecaoHorario, error) {
+	var e models.ExcecaoHorario
+	err := row.Scan(&e.ID, &e.SalaoID, &e.FuncionarioID, &e.Data, &e.Fechado, &e.Horario, &e.Descricao)
+	return e, err
+}
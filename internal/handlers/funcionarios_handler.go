@@ -3,12 +3,17 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/emaildoissa/agenda-flow/internal/disponibilidade"
 	"github.com/emaildoissa/agenda-flow/internal/models"
 	"github.com/go-chi/chi/v5"
 )
@@ -23,37 +28,391 @@ func NewFuncionariosHandler(db *sql.DB) *FuncionariosHandler {
 
 // ListFuncionariosBySalaoID lista todos os funcionários ativos de um salão.
 func (h *FuncionariosHandler) ListFuncionariosBySalaoID(w http.ResponseWriter, r *http.Request) {
-	salaoIDStr := chi.URLParam(r, "idSalao")
-	salaoID, err := strconv.Atoi(salaoIDStr)
+	salaoID, err := salaoDoEscopo(r)
 	if err != nil {
-		http.Error(w, "ID de salão inválido", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	rows, err := h.DB.Query("SELECT id, nome, ativo FROM funcionarios WHERE salao_id = $1 AND ativo = TRUE", salaoID)
+	funcionarios, err := buscarFuncionariosAtivos(r.Context(), h.DB, salaoID)
 	if err != nil {
 		log.Printf("Erro ao buscar funcionários: %v", err)
 		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
 		return
 	}
+
+	if funcionarios == nil {
+		funcionarios = make([]models.Funcionario, 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(funcionarios)
+}
+
+// CreateFuncionario cadastra um profissional no salão. Sem "horarios", ele
+// atende em todo o expediente do salão.
+func (h *FuncionariosHandler) CreateFuncionario(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var funcionario models.Funcionario
+	if err := json.NewDecoder(r.Body).Decode(&funcionario); err != nil {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+		return
+	}
+	if msg := validarFuncionario(funcionario); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	funcionario.SalaoID = salaoID
+	funcionario.Ativo = true
+	err = h.DB.QueryRowContext(r.Context(), `
+		INSERT INTO funcionarios (salao_id, nome, horarios)
+		VALUES ($1, $2, $3)
+		RETURNING id`,
+		salaoID, funcionario.Nome, funcionario.Horarios).Scan(&funcionario.ID)
+	if err != nil {
+		log.Printf("Erro ao inserir funcionário: %v", err)
+		http.Error(w, "Erro ao criar funcionário", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(funcionario)
+}
+
+// atualizarFuncionarioRequest é o corpo do PUT de um profissional. Ativo é
+// ponteiro para distinguir "não enviado" de false.
+type atualizarFuncionarioRequest struct {
+	models.Funcionario
+	Ativo *bool `json:"ativo"`
+}
+
+// UpdateFuncionario substitui os dados do profissional, incluindo o turno.
+// Enviar "horarios": null volta a seguir o expediente do salão; sem o campo
+// ativo, o profissional mantém a situação atual.
+func (h *FuncionariosHandler) UpdateFuncionario(w http.ResponseWriter, r *http.Request) {
+	salaoID, funcionarioID, ok := funcionarioDaURL(w, r)
+	if !ok {
+		return
+	}
+
+	var req atualizarFuncionarioRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+		return
+	}
+	funcionario := req.Funcionario
+	if msg := validarFuncionario(funcionario); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	err := h.DB.QueryRowContext(r.Context(), `
+		UPDATE funcionarios SET nome = $1, ativo = COALESCE($2, ativo), horarios = $3
+		WHERE id = $4 AND salao_id = $5
+		RETURNING ativo`,
+		funcionario.Nome, req.Ativo, funcionario.Horarios, funcionarioID, salaoID).Scan(&funcionario.Ativo)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Funcionário não encontrado", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erro ao atualizar funcionário: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	funcionario.ID, funcionario.SalaoID = funcionarioID, salaoID
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(funcionario)
+}
+
+// DeleteFuncionario desativa o profissional: ele deixa de receber novos
+// agendamentos, mas os já marcados com ele continuam valendo.
+func (h *FuncionariosHandler) DeleteFuncionario(w http.ResponseWriter, r *http.Request) {
+	salaoID, funcionarioID, ok := funcionarioDaURL(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := h.DB.ExecContext(r.Context(), "UPDATE funcionarios SET ativo = FALSE WHERE id = $1 AND salao_id = $2", funcionarioID, salaoID)
+	if err != nil {
+		log.Printf("Erro ao desativar funcionário: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Funcionário não encontrado", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// funcionarioDaURL lê o salão do escopo e o ID do funcionário da URL,
+// respondendo 400 se algum for inválido.
+func funcionarioDaURL(w http.ResponseWriter, r *http.Request) (salaoID, funcionarioID int, ok bool) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, 0, false
+	}
+	funcionarioID, err = strconv.Atoi(chi.URLParam(r, "idFuncionario"))
+	if err != nil {
+		http.Error(w, "ID de funcionário inválido", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return salaoID, funcionarioID, true
+}
+
+// validarFuncionario devolve a mensagem de erro, ou "" se estiver tudo certo.
+func validarFuncionario(f models.Funcionario) string {
+	if strings.TrimSpace(f.Nome) == "" {
+		return "O nome do funcionário é obrigatório"
+	}
+	return validarHorarios(f.Horarios)
+}
+
+// buscarFuncionariosAtivos devolve os funcionários ativos do salão, em ordem de ID.
+func buscarFuncionariosAtivos(ctx context.Context, db *sql.DB, salaoID int) ([]models.Funcionario, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, salao_id, nome, ativo, horarios FROM funcionarios WHERE salao_id = $1 AND ativo = TRUE ORDER BY id", salaoID)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	var funcionarios []models.Funcionario
 	for rows.Next() {
-		var f models.Funcionario
-		if err := rows.Scan(&f.ID, &f.Nome, &f.Ativo); err != nil {
-			log.Printf("Erro ao escanear funcionário: %v", err)
+		f, err := escanearFuncionario(rows)
+		if err != nil {
+			return nil, err
+		}
+		funcionarios = append(funcionarios, f)
+	}
+	return funcionarios, rows.Err()
+}
+
+// buscarFuncionario carrega um profissional do salão, ativo ou não.
+func buscarFuncionario(ctx context.Context, db *sql.DB, salaoID, funcionarioID int) (models.Funcionario, error) {
+	row := db.QueryRowContext(ctx, "SELECT id, salao_id, nome, ativo, horarios FROM funcionarios WHERE id = $1 AND salao_id = $2", funcionarioID, salaoID)
+	return escanearFuncionario(row)
+}
+
+func escanearFuncionario(row interface{ Scan(...any) error }) (models.Funcionario, error) {
+	var f models.Funcionario
+	err := row.Scan(&f.ID, &f.SalaoID, &f.Nome, &f.Ativo, &f.Horarios)
+	return f, err
+}
+
+// ListServicosDoFuncionario lista os serviços que o profissional realiza, com as
+// condições próprias dele quando houver.
+func (h *FuncionariosHandler) ListServicosDoFuncionario(w http.ResponseWriter, r *http.Request) {
+	salaoID, funcionarioID, ok := funcionarioDaURL(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT fs.funcionario_id, fs.servico_id, s.nome, fs.duracao_minutos, fs.preco
+		FROM funcionario_servicos fs
+		JOIN servicos s ON s.id = fs.servico_id
+		JOIN funcionarios f ON f.id = fs.funcionario_id
+		WHERE fs.funcionario_id = $1 AND f.salao_id = $2
+		ORDER BY s.ordem, s.id`,
+		funcionarioID, salaoID)
+	if err != nil {
+		log.Printf("Erro ao buscar serviços do funcionário: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	servicos := []models.FuncionarioServico{}
+	for rows.Next() {
+		var fs models.FuncionarioServico
+		if err := rows.Scan(&fs.FuncionarioID, &fs.ServicoID, &fs.ServicoNome, &fs.DuracaoMinutos, &fs.Preco); err != nil {
+			log.Printf("Erro ao escanear serviço do funcionário: %v", err)
 			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
 			return
 		}
-		funcionarios = append(funcionarios, f)
+		servicos = append(servicos, fs)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Erro ao buscar serviços do funcionário: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
 	}
 
-	if funcionarios == nil {
-		funcionarios = make([]models.Funcionario, 0)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(servicos)
+}
+
+// DefinirServicosDoFuncionario substitui a lista de serviços do profissional.
+// Um serviço sem nenhum profissional associado continua sendo oferecido por todos.
+func (h *FuncionariosHandler) DefinirServicosDoFuncionario(w http.ResponseWriter, r *http.Request) {
+	salaoID, funcionarioID, ok := funcionarioDaURL(w, r)
+	if !ok {
+		return
+	}
+
+	var servicos []models.FuncionarioServico
+	if err := json.NewDecoder(r.Body).Decode(&servicos); err != nil {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+		return
+	}
+	vistos := make(map[int]bool)
+	for _, fs := range servicos {
+		if vistos[fs.ServicoID] {
+			http.Error(w, fmt.Sprintf("Serviço %d repetido", fs.ServicoID), http.StatusBadRequest)
+			return
+		}
+		vistos[fs.ServicoID] = true
+		if fs.DuracaoMinutos != nil && (*fs.DuracaoMinutos <= 0 || *fs.DuracaoMinutos > 24*60) {
+			http.Error(w, "duracao_minutos deve estar entre 1 e 1440", http.StatusBadRequest)
+			return
+		}
+		if fs.Preco != nil && *fs.Preco < 0 {
+			http.Error(w, "O preço não pode ser negativo", http.StatusBadRequest)
+			return
+		}
 	}
 
+	if _, err := buscarFuncionario(r.Context(), h.DB, salaoID, funcionarioID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Funcionário não encontrado", http.StatusNotFound)
+		} else {
+			log.Printf("Erro ao buscar funcionário: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	tx, err := h.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Erro ao iniciar transação: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(r.Context(), "DELETE FROM funcionario_servicos WHERE funcionario_id = $1", funcionarioID); err != nil {
+		log.Printf("Erro ao limpar serviços do funcionário: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	for i, fs := range servicos {
+		// O serviço precisa ser do mesmo salão do profissional
+		res, err := tx.ExecContext(r.Context(), `
+			INSERT INTO funcionario_servicos (funcionario_id, servico_id, duracao_minutos, preco)
+			SELECT $1, id, $2, $3 FROM servicos WHERE id = $4 AND salao_id = $5`,
+			funcionarioID, fs.DuracaoMinutos, fs.Preco, fs.ServicoID, salaoID)
+		if err != nil {
+			log.Printf("Erro ao associar serviço ao funcionário: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, fmt.Sprintf("Serviço %d não encontrado", fs.ServicoID), http.StatusBadRequest)
+			return
+		}
+		servicos[i].FuncionarioID = funcionarioID
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Erro ao confirmar transação: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	if servicos == nil {
+		servicos = make([]models.FuncionarioServico, 0)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(funcionarios)
+	json.NewEncoder(w).Encode(servicos)
+}
+
+// RemoverServicoDoFuncionario desfaz a associação entre profissional e serviço.
+func (h *FuncionariosHandler) RemoverServicoDoFuncionario(w http.ResponseWriter, r *http.Request) {
+	salaoID, funcionarioID, ok := funcionarioDaURL(w, r)
+	if !ok {
+		return
+	}
+	servicoID, err := strconv.Atoi(chi.URLParam(r, "idServico"))
+	if err != nil {
+		http.Error(w, "ID de serviço inválido", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.DB.ExecContext(r.Context(), `
+		DELETE FROM funcionario_servicos fs
+		USING funcionarios f
+		WHERE f.id = fs.funcionario_id AND fs.funcionario_id = $1 AND fs.servico_id = $2 AND f.salao_id = $3`,
+		funcionarioID, servicoID, salaoID)
+	if err != nil {
+		log.Printf("Erro ao remover serviço do funcionário: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Associação não encontrada", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// profissionaisDoServico filtra, entre os funcionários informados, os que
+// realizam o serviço, devolvendo também as condições de cada um. Enquanto o
+// serviço não tiver nenhum profissional associado, todos o realizam.
+func profissionaisDoServico(ctx context.Context, db *sql.DB, servico models.Servico, funcionarios []models.Funcionario) ([]models.Funcionario, map[int]disponibilidade.Condicoes, error) {
+	rows, err := db.QueryContext(ctx, "SELECT funcionario_id, duracao_minutos, preco FROM funcionario_servicos WHERE servico_id = $1", servico.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	associados := make(map[int]disponibilidade.Condicoes)
+	for rows.Next() {
+		var funcionarioID int
+		var duracao *int
+		var preco *float64
+		if err := rows.Scan(&funcionarioID, &duracao, &preco); err != nil {
+			return nil, nil, err
+		}
+		c := disponibilidade.Condicoes{DuracaoMinutos: servico.DuracaoMinutos, Preco: servico.Preco}
+		if duracao != nil {
+			c.DuracaoMinutos = *duracao
+		}
+		if preco != nil {
+			c.Preco = *preco
+		}
+		associados[funcionarioID] = c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var qualificados []models.Funcionario
+	condicoes := make(map[int]disponibilidade.Condicoes)
+	for _, f := range funcionarios {
+		c, ok := associados[f.ID]
+		if len(associados) == 0 {
+			c, ok = disponibilidade.Condicoes{DuracaoMinutos: servico.DuracaoMinutos, Preco: servico.Preco}, true
+		}
+		if ok {
+			qualificados = append(qualificados, f)
+			condicoes[f.ID] = c
+		}
+	}
+	return qualificados, condicoes, nil
 }
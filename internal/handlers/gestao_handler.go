@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/agenda"
+	"github.com/emaildoissa/agenda-flow/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// Rotas públicas de autoatendimento: o cliente acessa o próprio agendamento
+// pelo token de gestão recebido no WhatsApp, sem login.
+
+// AgendamentoPublico é o que o cliente vê do próprio agendamento.
+type AgendamentoPublico struct {
+	SalaoNome       string    `json:"salao_nome"`
+	ServicoNome     string    `json:"servico_nome"`
+	FuncionarioNome *string   `json:"funcionario_nome"`
+	ClienteNome     string    `json:"cliente_nome"`
+	DataHoraInicio  time.Time `json:"data_hora_inicio"`
+	DataHoraFim     time.Time `json:"data_hora_fim"`
+	Status          string    `json:"status"`
+}
+
+// gerarTokenGestao cria um token aleatório e impossível de adivinhar (256 bits).
+func gerarTokenGestao() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// buscarPorToken localiza o agendamento do token, devolvendo salão e ID.
+func buscarPorToken(ctx context.Context, db *sql.DB, token string) (salaoID, agendamentoID int, err error) {
+	err = db.QueryRowContext(ctx, "SELECT salao_id, id FROM agendamentos WHERE token_gestao = $1", token).Scan(&salaoID, &agendamentoID)
+	return salaoID, agendamentoID, err
+}
+
+// GetPorToken mostra ao cliente o próprio agendamento.
+func (h *AgendamentosHandler) GetPorToken(w http.ResponseWriter, r *http.Request) {
+	var a AgendamentoPublico
+	err := h.DB.QueryRowContext(r.Context(), `
+		SELECT sa.nome_salao,
+		       COALESCE((SELECT string_agg(s.nome, ' + ' ORDER BY ags.posicao)
+		                 FROM agendamento_servicos ags JOIN servicos s ON s.id = ags.servico_id
+		                 WHERE ags.agendamento_id = a.id), s.nome),
+		       f.nome, a.cliente_nome, a.data_hora_inicio, a.data_hora_fim, a.status
+		FROM agendamentos a
+		JOIN saloes sa ON sa.id = a.salao_id
+		JOIN servicos s ON s.id = a.servico_id
+		LEFT JOIN funcionarios f ON f.id = a.funcionario_id
+		WHERE a.token_gestao = $1`,
+		chi.URLParam(r, "token")).Scan(&a.SalaoNome, &a.ServicoNome, &a.FuncionarioNome, &a.ClienteNome, &a.DataHoraInicio, &a.DataHoraFim, &a.Status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Agendamento não encontrado", http.StatusNotFound)
+		} else {
+			log.Printf("Erro ao buscar agendamento por token: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(a)
+}
+
+// CancelarPorToken permite ao cliente cancelar o próprio agendamento.
+func (h *AgendamentosHandler) CancelarPorToken(w http.ResponseWriter, r *http.Request) {
+	salaoID, agendamentoID, err := buscarPorToken(r.Context(), h.DB, chi.URLParam(r, "token"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Agendamento não encontrado", http.StatusNotFound)
+		} else {
+			log.Printf("Erro ao buscar agendamento por token: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var req alterarStatusRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+			return
+		}
+	}
+	if strings.TrimSpace(req.Motivo) == "" {
+		responderErroDeCampo(w, http.StatusBadRequest, "motivo", "Informe o motivo do cancelamento")
+		return
+	}
+
+	tx, err := h.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Erro ao iniciar transação: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	statusAnterior, err := agenda.Transicionar(r.Context(), tx, agenda.Alteracao{
+		AgendamentoID: agendamentoID,
+		SalaoID:       salaoID,
+		NovoStatus:    models.StatusCancelado,
+		Motivo:        req.Motivo,
+		AlteradoPor:   agenda.AutorCliente,
+		// O cliente só cancela sozinho dentro do prazo; depois disso precisa falar com o salão
+		ExigirAntecedencia: true,
+		Relogio:            h.Relogio,
+		Notificador:        h.Notificador,
+	})
+	if !responderErroTransicao(w, err, statusAnterior, models.StatusCancelado) {
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Erro ao confirmar transação: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "sucesso", "novo_status": models.StatusCancelado})
+}
+
+// ReagendarPorToken permite ao cliente mudar o horário do próprio agendamento,
+// com as mesmas verificações de disponibilidade da criação.
+func (h *AgendamentosHandler) ReagendarPorToken(w http.ResponseWriter, r *http.Request) {
+	salaoID, agendamentoID, err := buscarPorToken(r.Context(), h.DB, chi.URLParam(r, "token"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Agendamento não encontrado", http.StatusNotFound)
+		} else {
+			log.Printf("Erro ao buscar agendamento por token: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var req reagendarRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.DataHoraInicio.IsZero() {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+		return
+	}
+
+	h.reagendar(w, r, salaoID, agendamentoID, req.DataHoraInicio, agenda.AutorCliente, true)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/emaildoissa/agenda-flow/internal/auth"
+	"github.com/emaildoissa/agenda-flow/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	salaoDoToken = 7
+	outroSalao   = 8
+)
+
+// roteadorDeTeste monta as rotas como em cmd/api, sobre o banco falso.
+func roteadorDeTeste(conexao *sql.DB, tokens *auth.GerenciadorTokens) http.Handler {
+	somenteProprietario := chi.Chain(tokens.Autenticar, auth.ExigirMesmoSalao)
+	r := chi.NewRouter()
+
+	agendamentos := NewAgendamentosHandler(conexao, webhooks.NewNotificador(""))
+	r.With(tokens.Autenticar).Put("/agendamentos/{idAgendamento}/confirmar", agendamentos.ConfirmarAgendamento)
+	r.With(tokens.Autenticar).Put("/agendamentos/{idAgendamento}/cancelar", agendamentos.CancelarAgendamento)
+	r.With(tokens.Autenticar).Get("/agendamentos/{idAgendamento}/historico", agendamentos.GetHistorico)
+
+	servicos := NewServicosHandler(conexao)
+	r.With(somenteProprietario...).Post("/saloes/{idSalao}/servicos", servicos.CreateServico)
+	r.With(somenteProprietario...).Put("/saloes/{idSalao}/servicos/{idServico}", servicos.UpdateServico)
+	r.With(somenteProprietario...).Patch("/saloes/{idSalao}/servicos/{idServico}", servicos.PatchServico)
+	r.With(somenteProprietario...).Delete("/saloes/{idSalao}/servicos/{idServico}", servicos.DeleteServico)
+
+	funcionarios := NewFuncionariosHandler(conexao)
+	r.With(somenteProprietario...).Put("/saloes/{idSalao}/funcionarios/{idFuncionario}", funcionarios.UpdateFuncionario)
+	r.With(somenteProprietario...).Delete("/saloes/{idSalao}/funcionarios/{idFuncionario}", funcionarios.DeleteFuncionario)
+	r.With(somenteProprietario...).Put("/saloes/{idSalao}/funcionarios/{idFuncionario}/servicos", funcionarios.DefinirServicosDoFuncionario)
+
+	excecoes := NewExcecoesHandler(conexao)
+	r.With(somenteProprietario...).Delete("/saloes/{idSalao}/excecoes/{idExcecao}", excecoes.DeleteExcecao)
+
+	disponibilidade := NewDisponibilidadeHandler(conexao)
+	r.Get("/saloes/{idSalao}/disponibilidade", disponibilidade.GetDisponibilidade)
+
+	webhooks := NewWebhooksHandler(conexao)
+	r.With(somenteProprietario...).Put("/saloes/{idSalao}/webhooks/{idWebhook}", webhooks.UpdateEndpoint)
+	r.With(somenteProprietario...).Delete("/saloes/{idSalao}/webhooks/{idWebhook}", webhooks.DeleteEndpoint)
+	r.With(somenteProprietario...).Get("/saloes/{idSalao}/webhooks/{idWebhook}/entregas", webhooks.ListEntregas)
+	r.With(somenteProprietario...).Post("/saloes/{idSalao}/webhooks/falhas/{idEvento}/reenviar", webhooks.ReenviarFalha)
+
+	return r
+}
+
+func tokenDoSalao(t *testing.T, tokens *auth.GerenciadorTokens, salaoID int) string {
+	t.Helper()
+	par, err := tokens.GerarPar(salaoID)
+	if err != nil {
+		t.Fatalf("GerarPar: %v", err)
+	}
+	return par.AccessToken
+}
+
+// Os IDs usados são de registros do outro salão: o banco filtrado pelo salão
+// do escopo não os encontra, e a resposta precisa ser 404.
+func TestIDsDeOutroSalaoResultamEm404(t *testing.T) {
+	tokens := auth.NewGerenciadorTokens("segredo-de-teste")
+	token := tokenDoSalao(t, tokens, salaoDoToken)
+	salao := "/saloes/" + strconv.Itoa(salaoDoToken)
+
+	casos := []struct {
+		nome, metodo, caminho, corpo string
+		publica                      bool
+		tabela                       string // Tabela que a consulta do registro precisa ler
+	}{
+		{nome: "confirmar agendamento", metodo: http.MethodPut, caminho: "/agendamentos/901/confirmar", tabela: "agendamentos"},
+		{nome: "cancelar agendamento", metodo: http.MethodPut, caminho: "/agendamentos/901/cancelar", corpo: `{"motivo": "Cliente desistiu"}`, tabela: "agendamentos"},
+		{nome: "histórico do agendamento", metodo: http.MethodGet, caminho: "/agendamentos/901/historico", tabela: "agendamentos"},
+		{nome: "atualizar serviço", metodo: http.MethodPut, caminho: salao + "/servicos/902", corpo: `{"nome": "Corte", "duracao_minutos": 30, "preco": 50}`, tabela: "servicos"},
+		{nome: "alterar serviço", metodo: http.MethodPatch, caminho: salao + "/servicos/902", corpo: `{"preco": 60}`, tabela: "servicos"},
+		{nome: "excluir serviço", metodo: http.MethodDelete, caminho: salao + "/servicos/902", tabela: "servicos"},
+		{nome: "atualizar funcionário", metodo: http.MethodPut, caminho: salao + "/funcionarios/903", corpo: `{"nome": "Ana"}`, tabela: "funcionarios"},
+		{nome: "excluir funcionário", metodo: http.MethodDelete, caminho: salao + "/funcionarios/903", tabela: "funcionarios"},
+		{nome: "serviços do funcionário", metodo: http.MethodPut, caminho: salao + "/funcionarios/903/servicos", corpo: `[{"servico_id": 902}]`, tabela: "funcionarios"},
+		{nome: "excluir exceção", metodo: http.MethodDelete, caminho: salao + "/excecoes/904", tabela: "excecoes_horario"},
+		{nome: "disponibilidade de serviço de outro salão", metodo: http.MethodGet, caminho: salao + "/disponibilidade?servicoId=902&data=2025-03-10", publica: true, tabela: "servicos"},
+		{nome: "atualizar webhook", metodo: http.MethodPut, caminho: salao + "/webhooks/905", corpo: `{"url": "https://exemplo.com/gancho"}`, tabela: "webhook_endpoints"},
+		{nome: "excluir webhook", metodo: http.MethodDelete, caminho: salao + "/webhooks/905", tabela: "webhook_endpoints"},
+		{nome: "entregas do webhook", metodo: http.MethodGet, caminho: salao + "/webhooks/905/entregas", tabela: "webhook_endpoints"},
+		{nome: "reenviar falha", metodo: http.MethodPost, caminho: salao + "/webhooks/falhas/906/reenviar", tabela: "webhook_outbox"},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			db, banco := novoBancoFalso()
+			rota := roteadorDeTeste(db, tokens)
+
+			req := httptest.NewRequest(c.metodo, c.caminho, strings.NewReader(c.corpo))
+			if !c.publica {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			if c.corpo != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			rec := httptest.NewRecorder()
+			rota.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("status = %d (%s), esperado 404", rec.Code, strings.TrimSpace(rec.Body.String()))
+			}
+			executados := banco.executados()
+			leuTabela := false
+			for _, cmd := range executados {
+				leuTabela = leuTabela || strings.Contains(cmd.sql, c.tabela)
+				if !cmd.filtraPorSalao(salaoDoToken) {
+					t.Errorf("comando sem o salão do escopo: %s %v", cmd.sql, cmd.args)
+				}
+			}
+			if !leuTabela {
+				t.Errorf("nenhum comando em %s; o 404 não veio da busca do registro", c.tabela)
+			}
+		})
+	}
+}
+
+// Rotas /saloes/{idSalao}/... de outro salão são barradas antes de chegar ao banco.
+func TestRotaDeOutroSalaoEhNegada(t *testing.T) {
+	tokens := auth.NewGerenciadorTokens("segredo-de-teste")
+	db, banco := novoBancoFalso()
+	rota := roteadorDeTeste(db, tokens)
+
+	corpo := strings.NewReader(`{"nome": "Corte", "duracao_minutos": 30, "preco": 50}`)
+	req := httptest.NewRequest(http.MethodPost, "/saloes/"+strconv.Itoa(outroSalao)+"/servicos", corpo)
+	req.Header.Set("Authorization", "Bearer "+tokenDoSalao(t, tokens, salaoDoToken))
+	rec := httptest.NewRecorder()
+	rota.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, esperado 403", rec.Code)
+	}
+	if n := len(banco.executados()); n != 0 {
+		t.Errorf("%d comando(s) executado(s), esperado nenhum", n)
+	}
+}
+
+// Com um token, o escopo é o salão dele mesmo que a URL aponte outro.
+func TestSalaoDoEscopoPrefereOToken(t *testing.T) {
+	tokens := auth.NewGerenciadorTokens("segredo-de-teste")
+	r := chi.NewRouter()
+	escopo := func(w http.ResponseWriter, r *http.Request) {
+		salaoID, err := salaoDoEscopo(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(strconv.Itoa(salaoID)))
+	}
+	r.With(tokens.Autenticar).Get("/autenticado/saloes/{idSalao}", escopo)
+	r.Get("/publico/saloes/{idSalao}", escopo)
+
+	casos := []struct {
+		nome, caminho string
+		token         bool
+		esperado      string
+	}{
+		{"token vale sobre a URL", "/autenticado/saloes/" + strconv.Itoa(outroSalao), true, strconv.Itoa(salaoDoToken)},
+		{"rota pública usa a URL", "/publico/saloes/" + strconv.Itoa(outroSalao), false, strconv.Itoa(outroSalao)},
+		{"rota pública com ID inválido", "/publico/saloes/abc", false, errSalaoInvalido.Error()},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, c.caminho, nil)
+			if c.token {
+				req.Header.Set("Authorization", "Bearer "+tokenDoSalao(t, tokens, salaoDoToken))
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if got := strings.TrimSpace(rec.Body.String()); got != c.esperado {
+				t.Errorf("escopo = %q, esperado %q", got, c.esperado)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/auth"
+	"github.com/emaildoissa/agenda-flow/internal/models"
+	"github.com/emaildoissa/agenda-flow/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+)
+
+// relogioFixo devolve sempre o mesmo instante.
+type relogioFixo time.Time
+
+func (r relogioFixo) Agora() time.Time { return time.Time(r) }
+
+// salaoParaReagendar prepara no banco falso um salão sem funcionários, aberto
+// às segundas das 09:00 às 18:00 com pausa das 12:00 às 13:00, e um corte de
+// 60 minutos marcado para a quarta, 12/03/2025, às 15:00.
+func salaoParaReagendar(banco *bancoFalso) {
+	saoPaulo, _ := time.LoadLocation("America/Sao_Paulo")
+	inicio := time.Date(2025, 3, 12, 15, 0, 0, 0, saoPaulo)
+	fim := inicio.Add(time.Hour)
+	horarios := `{"segunda": {"inicio": "09:00", "fim": "18:00", "pausas": [{"inicio": "12:00", "fim": "13:00"}]}}`
+
+	banco.responder("token_gestao = $1", []string{"salao_id", "id"}, []driver.Value{int64(salaoDoToken), int64(501)})
+	banco.responder("reagendamentos FROM agendamentos",
+		[]string{"id", "servico_id", "funcionario_id", "data_hora_inicio", "data_hora_fim", "status", "reagendamentos"},
+		[]driver.Value{int64(501), int64(31), nil, inicio, fim, models.StatusConfirmado, int64(0)})
+	banco.responder("modo_confirmacao",
+		[]string{"modo_confirmacao", "minutos_reserva_pendente", "antecedencia_minima_cancelamento_horas", "max_reagendamentos",
+			"lembretes_minutos", "intervalo_slots_minutos", "minimizar_lacunas", "antecedencia_minima_reserva_minutos", "horizonte_reserva_dias"},
+		[]driver.Value{models.ModoConfirmacaoAutomatico, int64(30), int64(24), int64(2), []byte("[]"), int64(30), false, int64(60), int64(60)})
+	banco.responder("FOR UPDATE OF ags",
+		[]string{"posicao", "servico_id", "nome", "funcionario_id", "data_hora_inicio", "data_hora_fim", "bloqueio_inicio", "bloqueio_fim", "preco"},
+		[]driver.Value{int64(1), int64(31), "Corte", nil, inicio, fim, inicio, fim, 50.0})
+	banco.responder("FROM servicos WHERE id = $1",
+		[]string{"id", "salao_id", "nome", "duracao_minutos", "preco", "ativo", "ordem", "intervalo_slots_minutos", "buffer_antes_minutos", "buffer_depois_minutos"},
+		[]driver.Value{int64(31), int64(salaoDoToken), "Corte", int64(60), 50.0, true, int64(0), nil, int64(0), int64(0)})
+	banco.responder("horarios_funcionamento, fuso_horario", []string{"horarios_funcionamento", "fuso_horario"},
+		[]driver.Value{[]byte(horarios), "America/Sao_Paulo"})
+	banco.responder("sa.whatsapp_notificacao",
+		[]string{"id", "salao_id", "status", "cliente_nome", "cliente_contato", "funcionario_id",
+			"data_hora_inicio", "data_hora_fim", "token_gestao", "nome", "whatsapp_notificacao", "fuso_horario"},
+		[]driver.Value{int64(501), int64(salaoDoToken), models.StatusConfirmado, "Maria", "11999999999", nil,
+			inicio, fim, "tok", "Corte", "", "America/Sao_Paulo"})
+}
+
+// O reagendamento só aceita horários que a disponibilidade ofereceria, tanto
+// pelo link do cliente quanto pelo proprietário.
+func TestReagendarRecusaHorarioQueADisponibilidadeNaoOfereceria(t *testing.T) {
+	saoPaulo, _ := time.LoadLocation("America/Sao_Paulo")
+	agora := time.Date(2025, 3, 3, 8, 0, 0, 0, saoPaulo)
+	tokens := auth.NewGerenciadorTokens("segredo-de-teste")
+
+	casos := []struct {
+		nome, caminho, inicio string
+		proprietario          bool
+		status                int
+		mensagem              string
+	}{
+		{nome: "cliente fora da grade", caminho: "/agendamentos/gestao/tok/reagendar", inicio: "2025-03-10T10:15:00-03:00",
+			status: http.StatusUnprocessableEntity, mensagem: "não é oferecido"},
+		{nome: "cliente na pausa", caminho: "/agendamentos/gestao/tok/reagendar", inicio: "2025-03-10T12:00:00-03:00",
+			status: http.StatusUnprocessableEntity, mensagem: "pausa"},
+		{nome: "cliente invadindo a pausa", caminho: "/agendamentos/gestao/tok/reagendar", inicio: "2025-03-10T11:30:00-03:00",
+			status: http.StatusUnprocessableEntity, mensagem: "pausa"},
+		{nome: "proprietário fora da grade", caminho: "/agendamentos/501/reagendar", inicio: "2025-03-10T10:15:00-03:00", proprietario: true,
+			status: http.StatusUnprocessableEntity, mensagem: "não é oferecido"},
+		{nome: "proprietário sem antecedência", caminho: "/agendamentos/501/reagendar", inicio: "2025-03-03T08:30:00-03:00", proprietario: true,
+			status: http.StatusUnprocessableEntity, mensagem: "antecedência"},
+		{nome: "proprietário no passado", caminho: "/agendamentos/501/reagendar", inicio: "2025-03-01T10:00:00-03:00", proprietario: true,
+			status: http.StatusUnprocessableEntity, mensagem: "antecedência"},
+		{nome: "cliente em horário oferecido", caminho: "/agendamentos/gestao/tok/reagendar", inicio: "2025-03-10T10:00:00-03:00",
+			status: http.StatusOK},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			db, banco := novoBancoFalso()
+			salaoParaReagendar(banco)
+			rota := roteadorDeReagendamento(db, tokens, relogioFixo(agora))
+
+			metodo := http.MethodPost
+			if c.proprietario {
+				metodo = http.MethodPut
+			}
+			req := httptest.NewRequest(metodo, c.caminho, strings.NewReader(`{"data_hora_inicio": "`+c.inicio+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if c.proprietario {
+				req.Header.Set("Authorization", "Bearer "+tokenDoSalao(t, tokens, salaoDoToken))
+			}
+			rec := httptest.NewRecorder()
+			rota.ServeHTTP(rec, req)
+
+			if rec.Code != c.status {
+				t.Fatalf("status = %d (%s), esperado %d", rec.Code, strings.TrimSpace(rec.Body.String()), c.status)
+			}
+			if !strings.Contains(rec.Body.String(), c.mensagem) {
+				t.Errorf("resposta = %s, esperado conter %q", strings.TrimSpace(rec.Body.String()), c.mensagem)
+			}
+
+			var atualizou, notificou bool
+			for _, cmd := range banco.executados() {
+				// O evento leva o link de gestão do notificador do handler
+				if strings.Contains(cmd.sql, "INSERT INTO webhook_outbox") {
+					notificou = true
+					if payload, _ := cmd.args[2].([]byte); !strings.Contains(string(payload), linkGestaoDeTeste+"tok") {
+						t.Errorf("payload sem o link de gestão: %s", payload)
+					}
+				}
+				if strings.Contains(cmd.sql, "UPDATE agendamentos") {
+					atualizou = true
+					novoInicio, _ := time.Parse(time.RFC3339, c.inicio)
+					if inicio, ok := cmd.args[1].(time.Time); !ok || !inicio.Equal(novoInicio) {
+						t.Errorf("novo início gravado = %v, esperado %v", cmd.args[1], novoInicio)
+					}
+				}
+			}
+			if atualizou != (c.status == http.StatusOK) || notificou != atualizou {
+				t.Errorf("agendamento atualizado = %v e notificado = %v com status %d", atualizou, notificou, rec.Code)
+			}
+		})
+	}
+}
+
+const linkGestaoDeTeste = "https://app.exemplo.com/agendamento/"
+
+// roteadorDeReagendamento monta as duas rotas de reagendamento como em
+// cmd/api, com o relógio do teste.
+func roteadorDeReagendamento(conexao *sql.DB, tokens *auth.GerenciadorTokens, relogio relogioFixo) http.Handler {
+	agendamentos := NewAgendamentosHandler(conexao, webhooks.NewNotificador(linkGestaoDeTeste))
+	agendamentos.Relogio = relogio
+
+	r := chi.NewRouter()
+	r.With(tokens.Autenticar).Put("/agendamentos/{idAgendamento}/reagendar", agendamentos.ReagendarAgendamento)
+	r.Post("/agendamentos/gestao/{token}/reagendar", agendamentos.ReagendarPorToken)
+	return r
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/models"
+)
+
+type RelatoriosHandler struct {
+	DB *sql.DB
+}
+
+func NewRelatoriosHandler(db *sql.DB) *RelatoriosHandler {
+	return &RelatoriosHandler{DB: db}
+}
+
+// parametroData lê um parâmetro opcional no formato AAAA-MM-DD. Devolve nil
+// quando ausente, para que a consulta ignore o filtro.
+func parametroData(r *http.Request, nome string) (any, bool) {
+	valor := r.URL.Query().Get(nome)
+	if valor == "" {
+		return nil, true
+	}
+	if _, err := time.Parse("2006-01-02", valor); err != nil {
+		return nil, false
+	}
+	return valor, true
+}
+
+// ListCancelamentosTardios lista os clientes que cancelaram com menos
+// antecedência do que a política do salão pede, do que mais cancelou para o
+// que menos cancelou. O período (de/ate, inclusivos) considera a data do
+// agendamento no fuso do salão.
+func (h *RelatoriosHandler) ListCancelamentosTardios(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	de, ok := parametroData(r, "de")
+	if !ok {
+		http.Error(w, "Parâmetro 'de' inválido. Use o formato AAAA-MM-DD", http.StatusBadRequest)
+		return
+	}
+	ate, ok := parametroData(r, "ate")
+	if !ok {
+		http.Error(w, "Parâmetro 'ate' inválido. Use o formato AAAA-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT a.cliente_contato, (array_agg(a.cliente_nome ORDER BY a.data_hora_inicio DESC))[1],
+		       COUNT(*), MAX(a.data_hora_inicio)
+		FROM agendamentos a
+		JOIN saloes s ON s.id = a.salao_id
+		WHERE a.salao_id = $1 AND a.status = $2 AND a.cancelamento_tardio
+		  AND ($3::date IS NULL OR (a.data_hora_inicio AT TIME ZONE s.fuso_horario)::date >= $3::date)
+		  AND ($4::date IS NULL OR (a.data_hora_inicio AT TIME ZONE s.fuso_horario)::date <= $4::date)
+		GROUP BY a.cliente_contato
+		ORDER BY COUNT(*) DESC, MAX(a.data_hora_inicio) DESC`,
+		salaoID, models.StatusCancelado, de, ate)
+	if err != nil {
+		log.Printf("Erro ao gerar relatório de cancelamentos tardios: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	clientes := []models.CancelamentosTardiosCliente{}
+	for rows.Next() {
+		var c models.CancelamentosTardiosCliente
+		if err := rows.Scan(&c.ClienteContato, &c.ClienteNome, &c.CancelamentosTardios, &c.UltimoAgendamentoEm); err != nil {
+			log.Printf("Erro ao ler relatório de cancelamentos tardios: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			return
+		}
+		clientes = append(clientes, c)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Erro ao ler relatório de cancelamentos tardios: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(clientes)
+}
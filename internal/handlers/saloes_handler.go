@@ -1,18 +1,27 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/emaildoissa/agenda-flow/internal/disponibilidade"
 	"github.com/emaildoissa/agenda-flow/internal/models"
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// fusoHorarioPadrao é usado quando o salão não informa o seu fuso
+const fusoHorarioPadrao = "America/Sao_Paulo"
+
 // SaloesHandler é uma struct que segura as dependências, como o banco de dados.
 // Isso facilita os testes e a organização.
 type SaloesHandler struct {
@@ -45,6 +54,19 @@ func (h *SaloesHandler) CreateSalao(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// O fuso precisa ser um nome IANA válido (ex.: "America/Manaus")
+	if salao.FusoHorario == "" {
+		salao.FusoHorario = fusoHorarioPadrao
+	}
+	if _, err := time.LoadLocation(salao.FusoHorario); err != nil {
+		http.Error(w, "Fuso horário inválido", http.StatusBadRequest)
+		return
+	}
+	if msg := validarHorarios(salao.HorariosFuncionamento); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
 	// 3. Hashear a senha recebida usando bcrypt
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(salao.HashSenha), bcrypt.DefaultCost)
 	if err != nil {
@@ -56,8 +78,8 @@ func (h *SaloesHandler) CreateSalao(w http.ResponseWriter, r *http.Request) {
 
 	// 4. Inserir o novo salão no banco de dados
 	sqlStatement := `
-		INSERT INTO saloes (nome_salao, email_proprietario, hash_senha, whatsapp_notificacao, horarios_funcionamento)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO saloes (nome_salao, email_proprietario, hash_senha, whatsapp_notificacao, horarios_funcionamento, fuso_horario)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, criado_em`
 
 	// Usamos QueryRow para pegar o ID e criado_em que foram gerados pelo banco
@@ -68,6 +90,7 @@ func (h *SaloesHandler) CreateSalao(w http.ResponseWriter, r *http.Request) {
 		salao.HashSenha,
 		salao.WhatsappNotificacao,
 		salao.HorariosFuncionamento, // Por enquanto, pode ser nulo
+		salao.FusoHorario,
 	).Scan(&salao.ID, &salao.CriadoEm)
 
 	if err != nil {
@@ -97,7 +120,7 @@ func (h *SaloesHandler) GetSalaoByID(w http.ResponseWriter, r *http.Request) {
 	// 2. Buscar o salão no banco de dados
 	var salao models.Salao
 	sqlStatement := `
-		SELECT id, nome_salao, email_proprietario, whatsapp_notificacao, horarios_funcionamento, criado_em
+		SELECT id, nome_salao, email_proprietario, whatsapp_notificacao, horarios_funcionamento, fuso_horario, criado_em
 		FROM saloes
 		WHERE id = $1`
 
@@ -108,6 +131,7 @@ func (h *SaloesHandler) GetSalaoByID(w http.ResponseWriter, r *http.Request) {
 		&salao.EmailProprietario,
 		&salao.WhatsappNotificacao,
 		&salao.HorariosFuncionamento,
+		&salao.FusoHorario,
 		&salao.CriadoEm,
 	)
 
@@ -129,3 +153,288 @@ func (h *SaloesHandler) GetSalaoByID(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK) // Status 200 OK
 	json.NewEncoder(w).Encode(salao)
 }
+
+// UpdateSalao altera os dados cadastrais do salão. Campos omitidos no corpo
+// mantêm o valor atual; senha e horários têm rotas próprias.
+func (h *SaloesHandler) UpdateSalao(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var salao models.Salao
+	err = h.DB.QueryRowContext(r.Context(), `
+		SELECT id, nome_salao, email_proprietario, whatsapp_notificacao, horarios_funcionamento, fuso_horario, criado_em
+		FROM saloes WHERE id = $1`, salaoID).Scan(
+		&salao.ID, &salao.NomeSalao, &salao.EmailProprietario, &salao.WhatsappNotificacao,
+		&salao.HorariosFuncionamento, &salao.FusoHorario, &salao.CriadoEm)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Salão não encontrado", http.StatusNotFound)
+		} else {
+			log.Printf("Erro ao buscar salão: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Só os dados cadastrais podem mudar por aqui
+	var req struct {
+		NomeSalao           *string `json:"nome_salao"`
+		EmailProprietario   *string `json:"email_proprietario"`
+		WhatsappNotificacao *string `json:"whatsapp_notificacao"`
+		FusoHorario         *string `json:"fuso_horario"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+		return
+	}
+	if req.NomeSalao != nil {
+		salao.NomeSalao = strings.TrimSpace(*req.NomeSalao)
+	}
+	if req.EmailProprietario != nil {
+		salao.EmailProprietario = strings.TrimSpace(*req.EmailProprietario)
+	}
+	if req.WhatsappNotificacao != nil {
+		salao.WhatsappNotificacao = strings.TrimSpace(*req.WhatsappNotificacao)
+	}
+	if req.FusoHorario != nil {
+		salao.FusoHorario = strings.TrimSpace(*req.FusoHorario)
+	}
+
+	if salao.NomeSalao == "" || salao.EmailProprietario == "" || salao.WhatsappNotificacao == "" {
+		http.Error(w, "Campos obrigatórios estão faltando", http.StatusBadRequest)
+		return
+	}
+	if _, err := time.LoadLocation(salao.FusoHorario); err != nil {
+		http.Error(w, "Fuso horário inválido", http.StatusBadRequest)
+		return
+	}
+
+	_, err = h.DB.ExecContext(r.Context(), `
+		UPDATE saloes SET nome_salao = $1, email_proprietario = $2, whatsapp_notificacao = $3, fuso_horario = $4
+		WHERE id = $5`,
+		salao.NomeSalao, salao.EmailProprietario, salao.WhatsappNotificacao, salao.FusoHorario, salaoID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			http.Error(w, "Já existe um salão com este e-mail", http.StatusConflict)
+			return
+		}
+		log.Printf("Erro ao atualizar salão: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(salao)
+}
+
+// UpdateHorarios substitui os horários de funcionamento do salão. Dias
+// ausentes no corpo passam a ser dias fechados.
+func (h *SaloesHandler) UpdateHorarios(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var horarios models.HorarioSemanal
+	if err := json.NewDecoder(r.Body).Decode(&horarios); err != nil {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+		return
+	}
+	if horarios == nil {
+		horarios = models.HorarioSemanal{}
+	}
+	if msg := validarHorarios(horarios); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.DB.ExecContext(r.Context(), "UPDATE saloes SET horarios_funcionamento = $1 WHERE id = $2", horarios, salaoID)
+	if err != nil {
+		log.Printf("Erro ao atualizar horários do salão: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Salão não encontrado", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(horarios)
+}
+
+// GetConfiguracoes devolve as regras de agendamento do salão.
+func (h *SaloesHandler) GetConfiguracoes(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	config, err := carregarConfiguracoes(r.Context(), h.DB, salaoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Salão não encontrado", http.StatusNotFound)
+		} else {
+			log.Printf("Erro ao buscar configurações do salão: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(config)
+}
+
+// UpdateConfiguracoes altera as regras de agendamento. Campos omitidos no
+// corpo mantêm o valor atual.
+func (h *SaloesHandler) UpdateConfiguracoes(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	config, err := carregarConfiguracoes(r.Context(), h.DB, salaoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Salão não encontrado", http.StatusNotFound)
+		} else {
+			log.Printf("Erro ao buscar configurações do salão: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+		return
+	}
+	if config.LembretesMinutos == nil {
+		config.LembretesMinutos = []int{} // "null" no corpo desliga os lembretes
+	}
+	if msg := validarConfiguracoes(config); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	_, err = h.DB.ExecContext(r.Context(), `
+		UPDATE saloes SET modo_confirmacao = $1, minutos_reserva_pendente = $2,
+		       antecedencia_minima_cancelamento_horas = $3, max_reagendamentos = $4,
+		       lembretes_minutos = $5::int[], intervalo_slots_minutos = $6, minimizar_lacunas = $7,
+		       antecedencia_minima_reserva_minutos = $8, horizonte_reserva_dias = $9
+		WHERE id = $10`,
+		config.ModoConfirmacao, config.MinutosReservaPendente,
+		config.AntecedenciaMinimaCancelamentoHoras, config.MaxReagendamentos, config.LembretesMinutos,
+		config.IntervaloSlotsMinutos, config.MinimizarLacunas,
+		config.AntecedenciaMinimaReservaMinutos, config.HorizonteReservaDias, salaoID)
+	if err != nil {
+		log.Printf("Erro ao atualizar configurações do salão: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(config)
+}
+
+// carregarConfiguracoes lê as regras de agendamento do salão.
+func carregarConfiguracoes(ctx context.Context, db *sql.DB, salaoID int) (models.ConfiguracoesSalao, error) {
+	var config models.ConfiguracoesSalao
+	var lembretesJSON []byte
+	err := db.QueryRowContext(ctx, `
+		SELECT modo_confirmacao, minutos_reserva_pendente,
+		       antecedencia_minima_cancelamento_horas, max_reagendamentos, to_jsonb(lembretes_minutos),
+		       intervalo_slots_minutos, minimizar_lacunas,
+		       antecedencia_minima_reserva_minutos, horizonte_reserva_dias
+		FROM saloes WHERE id = $1`,
+		salaoID).Scan(&config.ModoConfirmacao, &config.MinutosReservaPendente,
+		&config.AntecedenciaMinimaCancelamentoHoras, &config.MaxReagendamentos, &lembretesJSON,
+		&config.IntervaloSlotsMinutos, &config.MinimizarLacunas,
+		&config.AntecedenciaMinimaReservaMinutos, &config.HorizonteReservaDias)
+	if err != nil {
+		return config, err
+	}
+	err = json.Unmarshal(lembretesJSON, &config.LembretesMinutos)
+	return config, err
+}
+
+// validarConfiguracoes devolve a mensagem de erro, ou "" se estiver tudo certo.
+func validarConfiguracoes(config models.ConfiguracoesSalao) string {
+	if config.ModoConfirmacao != models.ModoConfirmacaoAutomatico && config.ModoConfirmacao != models.ModoConfirmacaoManual {
+		return "modo_confirmacao deve ser AUTOMATICO ou MANUAL"
+	}
+	if config.MinutosReservaPendente < 5 || config.MinutosReservaPendente > 7*24*60 {
+		return "minutos_reserva_pendente deve estar entre 5 e 10080"
+	}
+	if config.AntecedenciaMinimaCancelamentoHoras < 0 || config.AntecedenciaMinimaCancelamentoHoras > 720 {
+		return "antecedencia_minima_cancelamento_horas deve estar entre 0 e 720"
+	}
+	if config.MaxReagendamentos < 0 {
+		return "max_reagendamentos não pode ser negativo"
+	}
+	if len(config.LembretesMinutos) > 5 {
+		return "lembretes_minutos aceita no máximo 5 lembretes"
+	}
+	vistos := make(map[int]bool)
+	for _, m := range config.LembretesMinutos {
+		if m < 5 || m > 7*24*60 {
+			return "cada item de lembretes_minutos deve estar entre 5 e 10080"
+		}
+		if vistos[m] {
+			return "lembretes_minutos não pode ter valores repetidos"
+		}
+		vistos[m] = true
+	}
+	if !intervaloSlotsValido(config.IntervaloSlotsMinutos) {
+		return "intervalo_slots_minutos deve ser 10, 15, 20 ou 30"
+	}
+	if config.AntecedenciaMinimaReservaMinutos < 0 || config.AntecedenciaMinimaReservaMinutos > 30*24*60 {
+		return "antecedencia_minima_reserva_minutos deve estar entre 0 e 43200"
+	}
+	if config.HorizonteReservaDias < 1 || config.HorizonteReservaDias > 730 {
+		return "horizonte_reserva_dias deve estar entre 1 e 730"
+	}
+	return ""
+}
+
+// janelaDoSalao monta a janela de reservas do salão a partir das configurações.
+func janelaDoSalao(config models.ConfiguracoesSalao, loc *time.Location) disponibilidade.JanelaReserva {
+	return disponibilidade.JanelaReserva{
+		AntecedenciaMinima: time.Duration(config.AntecedenciaMinimaReservaMinutos) * time.Minute,
+		HorizonteDias:      config.HorizonteReservaDias,
+		Loc:                loc,
+	}
+}
+
+// mensagemJanela explica ao cliente por que o horário está fora da janela de
+// reservas, ou devolve "" se o erro não for de janela.
+func mensagemJanela(err error, config models.ConfiguracoesSalao) string {
+	switch {
+	case errors.Is(err, disponibilidade.ErrAntecedenciaMinima):
+		if config.AntecedenciaMinimaReservaMinutos == 0 {
+			return "Não é possível agendar um horário que já passou"
+		}
+		return fmt.Sprintf("Agendamentos exigem pelo menos %d minuto(s) de antecedência", config.AntecedenciaMinimaReservaMinutos)
+	case errors.Is(err, disponibilidade.ErrForaDoHorizonte):
+		return fmt.Sprintf("A agenda só está aberta para os próximos %d dia(s)", config.HorizonteReservaDias)
+	}
+	return ""
+}
+
+// intervaloSlotsValido diz se o intervalo entre horários oferecidos é um dos aceitos.
+func intervaloSlotsValido(minutos int) bool {
+	switch minutos {
+	case 10, 15, 20, 30:
+		return true
+	}
+	return false
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/emaildoissa/agenda-flow/internal/models"
 	"github.com/go-chi/chi/v5"
@@ -24,10 +27,9 @@ func NewServicosHandler(db *sql.DB) *ServicosHandler {
 // CreateServico adiciona um novo serviço a um salão.
 func (h *ServicosHandler) CreateServico(w http.ResponseWriter, r *http.Request) {
 	// 1. Pegar o ID do salão da URL.
-	salaoIDStr := chi.URLParam(r, "idSalao")
-	salaoID, err := strconv.Atoi(salaoIDStr)
+	salaoID, err := salaoDoEscopo(r)
 	if err != nil {
-		http.Error(w, "ID de salão inválido", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -39,14 +41,21 @@ func (h *ServicosHandler) CreateServico(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if msg := validarServico(servico); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
 	// 3. Inserir no banco de dados.
 	servico.SalaoID = salaoID // Garante que o serviço seja associado ao salão correto.
+	servico.Ativo = true
 	sqlStatement := `
-		INSERT INTO servicos (salao_id, nome, duracao_minutos, preco)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO servicos (salao_id, nome, duracao_minutos, preco, ordem, intervalo_slots_minutos, buffer_antes_minutos, buffer_depois_minutos)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id`
 
-	err = h.DB.QueryRow(sqlStatement, servico.SalaoID, servico.Nome, servico.DuracaoMinutos, servico.Preco).Scan(&servico.ID)
+	err = h.DB.QueryRow(sqlStatement, servico.SalaoID, servico.Nome, servico.DuracaoMinutos, servico.Preco, servico.Ordem,
+		servico.IntervaloSlotsMinutos, servico.BufferAntesMinutos, servico.BufferDepoisMinutos).Scan(&servico.ID)
 	if err != nil {
 		log.Printf("Erro ao inserir serviço: %v", err)
 		http.Error(w, "Erro ao criar serviço", http.StatusInternalServerError)
@@ -62,15 +71,16 @@ func (h *ServicosHandler) CreateServico(w http.ResponseWriter, r *http.Request)
 // ListServicosBySalaoID lista todos os serviços de um salão específico.
 func (h *ServicosHandler) ListServicosBySalaoID(w http.ResponseWriter, r *http.Request) {
 	// 1. Pegar o ID do salão da URL.
-	salaoIDStr := chi.URLParam(r, "idSalao")
-	salaoID, err := strconv.Atoi(salaoIDStr)
+	salaoID, err := salaoDoEscopo(r)
 	if err != nil {
-		http.Error(w, "ID de salão inválido", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// 2. Buscar os serviços no banco.
-	rows, err := h.DB.Query("SELECT id, salao_id, nome, duracao_minutos, preco, ativo FROM servicos WHERE salao_id = $1 AND ativo = TRUE", salaoID)
+	rows, err := h.DB.Query(`
+		SELECT id, salao_id, nome, duracao_minutos, preco, ativo, ordem, intervalo_slots_minutos, buffer_antes_minutos, buffer_depois_minutos
+		FROM servicos WHERE salao_id = $1 AND ativo = TRUE ORDER BY ordem, id`, salaoID)
 	if err != nil {
 		log.Printf("Erro ao buscar serviços: %v", err)
 		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
@@ -81,8 +91,8 @@ func (h *ServicosHandler) ListServicosBySalaoID(w http.ResponseWriter, r *http.R
 	// 3. Iterar sobre os resultados e construir a lista.
 	var servicos []models.Servico
 	for rows.Next() {
-		var s models.Servico
-		if err := rows.Scan(&s.ID, &s.SalaoID, &s.Nome, &s.DuracaoMinutos, &s.Preco, &s.Ativo); err != nil {
+		s, err := escanearServico(rows)
+		if err != nil {
 			log.Printf("Erro ao escanear serviço: %v", err)
 			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
 			return
@@ -101,3 +111,222 @@ func (h *ServicosHandler) ListServicosBySalaoID(w http.ResponseWriter, r *http.R
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(servicos)
 }
+
+// atualizarServicoRequest é o corpo do PUT de um serviço. Ativo é ponteiro
+// para distinguir "não enviado" de false.
+type atualizarServicoRequest struct {
+	models.Servico
+	Ativo *bool `json:"ativo"`
+}
+
+// UpdateServico substitui todos os dados do serviço. Sem o campo ativo, o
+// serviço mantém a situação atual.
+func (h *ServicosHandler) UpdateServico(w http.ResponseWriter, r *http.Request) {
+	salaoID, servicoID, ok := servicoDaURL(w, r)
+	if !ok {
+		return
+	}
+
+	var req atualizarServicoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+		return
+	}
+	atual, err := buscarServico(r.Context(), h.DB, salaoID, servicoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Serviço não encontrado", http.StatusNotFound)
+		} else {
+			log.Printf("Erro ao buscar serviço: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	servico := req.Servico
+	servico.Ativo = atual.Ativo
+	if req.Ativo != nil {
+		servico.Ativo = *req.Ativo
+	}
+	servico.ID, servico.SalaoID = servicoID, salaoID
+	h.salvarServico(w, r, servico)
+}
+
+// PatchServico altera só os campos enviados; os demais mantêm o valor atual.
+func (h *ServicosHandler) PatchServico(w http.ResponseWriter, r *http.Request) {
+	salaoID, servicoID, ok := servicoDaURL(w, r)
+	if !ok {
+		return
+	}
+
+	servico, err := buscarServico(r.Context(), h.DB, salaoID, servicoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Serviço não encontrado", http.StatusNotFound)
+		} else {
+			log.Printf("Erro ao buscar serviço: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&servico); err != nil {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+		return
+	}
+	servico.ID, servico.SalaoID = servicoID, salaoID
+	h.salvarServico(w, r, servico)
+}
+
+// DeleteServico desativa o serviço. Ele some da página de agendamento, mas os
+// agendamentos que já o usam continuam intactos.
+func (h *ServicosHandler) DeleteServico(w http.ResponseWriter, r *http.Request) {
+	salaoID, servicoID, ok := servicoDaURL(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := h.DB.ExecContext(r.Context(), "UPDATE servicos SET ativo = FALSE WHERE id = $1 AND salao_id = $2", servicoID, salaoID)
+	if err != nil {
+		log.Printf("Erro ao desativar serviço: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Serviço não encontrado", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type reordenarServicosRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// ReordenarServicos define a ordem de exibição: o primeiro ID da lista fica na
+// posição 1, o segundo na 2, e assim por diante. Serviços fora da lista mantêm a ordem atual.
+func (h *ServicosHandler) ReordenarServicos(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req reordenarServicosRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.IDs) == 0 {
+		http.Error(w, "Informe a lista 'ids' com os serviços na ordem desejada", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Erro ao iniciar transação: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	for i, id := range req.IDs {
+		res, err := tx.ExecContext(r.Context(), "UPDATE servicos SET ordem = $1 WHERE id = $2 AND salao_id = $3", i+1, id, salaoID)
+		if err != nil {
+			log.Printf("Erro ao reordenar serviços: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Serviço "+strconv.Itoa(id)+" não encontrado", http.StatusNotFound)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Erro ao confirmar transação: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// salvarServico valida e grava as alterações, respondendo com o serviço atualizado.
+func (h *ServicosHandler) salvarServico(w http.ResponseWriter, r *http.Request, servico models.Servico) {
+	if msg := validarServico(servico); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.DB.ExecContext(r.Context(), `
+		UPDATE servicos SET nome = $1, duracao_minutos = $2, preco = $3, ativo = $4, ordem = $5,
+		       intervalo_slots_minutos = $6, buffer_antes_minutos = $7, buffer_depois_minutos = $8
+		WHERE id = $9 AND salao_id = $10`,
+		servico.Nome, servico.DuracaoMinutos, servico.Preco, servico.Ativo, servico.Ordem,
+		servico.IntervaloSlotsMinutos, servico.BufferAntesMinutos, servico.BufferDepoisMinutos, servico.ID, servico.SalaoID)
+	if err != nil {
+		log.Printf("Erro ao atualizar serviço: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Serviço não encontrado", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(servico)
+}
+
+// servicoDaURL lê o salão do escopo e o ID do serviço da URL, respondendo 400
+// se algum for inválido.
+func servicoDaURL(w http.ResponseWriter, r *http.Request) (salaoID, servicoID int, ok bool) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, 0, false
+	}
+	servicoID, err = strconv.Atoi(chi.URLParam(r, "idServico"))
+	if err != nil {
+		http.Error(w, "ID de serviço inválido", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return salaoID, servicoID, true
+}
+
+// buscarServico carrega um serviço do salão, ativo ou não.
+func buscarServico(ctx context.Context, db *sql.DB, salaoID, servicoID int) (models.Servico, error) {
+	return escanearServico(db.QueryRowContext(ctx, `
+		SELECT id, salao_id, nome, duracao_minutos, preco, ativo, ordem, intervalo_slots_minutos, buffer_antes_minutos, buffer_depois_minutos
+		FROM servicos WHERE id = $1 AND salao_id = $2`,
+		servicoID, salaoID))
+}
+
+// escanearServico lê um serviço na ordem de colunas usada nas consultas acima.
+func escanearServico(row interface{ Scan(...any) error }) (models.Servico, error) {
+	var s models.Servico
+	err := row.Scan(&s.ID, &s.SalaoID, &s.Nome, &s.DuracaoMinutos, &s.Preco, &s.Ativo, &s.Ordem,
+		&s.IntervaloSlotsMinutos, &s.BufferAntesMinutos, &s.BufferDepoisMinutos)
+	return s, err
+}
+
+// validarServico devolve a mensagem de erro, ou "" se estiver tudo certo.
+func validarServico(s models.Servico) string {
+	if strings.TrimSpace(s.Nome) == "" {
+		return "O nome do serviço é obrigatório"
+	}
+	if s.DuracaoMinutos <= 0 || s.DuracaoMinutos > 24*60 {
+		return "duracao_minutos deve estar entre 1 e 1440"
+	}
+	if s.Preco < 0 {
+		return "O preço não pode ser negativo"
+	}
+	if s.Ordem < 0 {
+		return "A ordem não pode ser negativa"
+	}
+	if s.IntervaloSlotsMinutos != nil && !intervaloSlotsValido(*s.IntervaloSlotsMinutos) {
+		return "intervalo_slots_minutos deve ser 10, 15, 20 ou 30 (ou nulo para usar o do salão)"
+	}
+	if s.BufferAntesMinutos < 0 || s.BufferAntesMinutos > 240 || s.BufferDepoisMinutos < 0 || s.BufferDepoisMinutos > 240 {
+		return "buffer_antes_minutos e buffer_depois_minutos devem estar entre 0 e 240"
+	}
+	return ""
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emaildoissa/agenda-flow/internal/disponibilidade"
+	"github.com/emaildoissa/agenda-flow/internal/models"
+)
+
+// respostaValidacao detalha, campo a campo, por que a requisição foi recusada.
+// As chaves de Campos são os nomes dos campos no JSON enviado.
+type respostaValidacao struct {
+	Erro   string            `json:"erro"`
+	Campos map[string]string `json:"campos"`
+}
+
+// responderErroDeCampo recusa a requisição apontando um único campo.
+func responderErroDeCampo(w http.ResponseWriter, status int, campo, msg string) {
+	responderErrosDeCampo(w, status, map[string]string{campo: msg})
+}
+
+// responderErrosDeCampo recusa a requisição com as mensagens de cada campo.
+func responderErrosDeCampo(w http.ResponseWriter, status int, campos map[string]string) {
+	resposta := respostaValidacao{Erro: "Verifique os campos informados", Campos: campos}
+	if len(campos) == 1 {
+		for _, msg := range campos {
+			resposta.Erro = msg
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resposta)
+}
+
+// validarNovoAgendamento confere os campos obrigatórios de um agendamento
+// recebido do cliente. Os serviços de uma visita com vários serviços são
+// apontados como "servicos[i].campo". Devolve as mensagens por campo (vazio se estiver tudo certo).
+func validarNovoAgendamento(a models.Agendamento) map[string]string {
+	campos := make(map[string]string)
+	if a.SalaoID <= 0 {
+		campos["salao_id"] = "Informe o salão"
+	}
+	if len(a.Servicos) == 0 && a.ServicoID <= 0 {
+		campos["servico_id"] = "Informe o serviço"
+	}
+	if len(a.Servicos) > maxServicosVisita {
+		campos["servicos"] = fmt.Sprintf("Informe no máximo %d serviços por visita", maxServicosVisita)
+	}
+	for i, s := range a.Servicos {
+		if s.ServicoID <= 0 {
+			campos[fmt.Sprintf("servicos[%d].servico_id", i)] = "Informe o serviço"
+		}
+	}
+	if strings.TrimSpace(a.ClienteNome) == "" {
+		campos["cliente_nome"] = "Informe o nome do cliente"
+	}
+	if strings.TrimSpace(a.ClienteContato) == "" {
+		campos["cliente_contato"] = "Informe um contato do cliente"
+	}
+	if a.DataHoraInicio.IsZero() {
+		campos["data_hora_inicio"] = "Informe a data e a hora do agendamento"
+	}
+	return campos
+}
+
+// mensagemRecusa explica por que o motor de disponibilidade recusou o horário.
+// profissionalEscolhido diferencia "o profissional" de "nenhum profissional".
+func mensagemRecusa(err error, config models.ConfiguracoesSalao, profissionalEscolhido bool) string {
+	if msg := mensagemJanela(err, config); msg != "" {
+		return msg
+	}
+	switch {
+	case errors.Is(err, disponibilidade.ErrSalaoFechado):
+		return "O salão não funciona neste dia"
+	case errors.Is(err, disponibilidade.ErrForaDoExpediente):
+		return "Horário fora do expediente do salão"
+	case errors.Is(err, disponibilidade.ErrPausa):
+		return "Horário coincide com uma pausa do salão"
+	case errors.Is(err, disponibilidade.ErrForaDoTurno) && profissionalEscolhido:
+		return "O profissional não atende neste horário"
+	case errors.Is(err, disponibilidade.ErrForaDoTurno):
+		return "Nenhum profissional atende neste horário"
+	case errors.Is(err, disponibilidade.ErrSemProfissional):
+		return "Nenhum profissional realiza este serviço"
+	case errors.Is(err, disponibilidade.ErrForaDaGrade):
+		return "Este horário não é oferecido para o serviço; consulte a disponibilidade"
+	}
+	return "Horário indisponível"
+}
+
+// validarHorarios confere o formato de um horário semanal informado pelo
+// usuário. Devolve a mensagem de erro, ou "" se estiver tudo certo.
+func validarHorarios(horarios models.HorarioSemanal) string {
+	diasValidos := make(map[string]bool, len(disponibilidade.DiasDaSemana))
+	for _, d := range disponibilidade.DiasDaSemana {
+		diasValidos[d] = true
+	}
+	for dia, h := range horarios {
+		if !diasValidos[dia] {
+			return fmt.Sprintf("Dia da semana inválido: '%s'. Use %s", dia, strings.Join(disponibilidade.DiasDaSemana[:], ", "))
+		}
+		if msg := validarHorarioDia(dia, h); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// validarHorarioDia confere o expediente de um dia; rotulo identifica o dia
+// nas mensagens. Um expediente nulo (dia fechado) é válido.
+func validarHorarioDia(rotulo string, h *models.HorarioDia) string {
+	if h == nil {
+		return ""
+	}
+	if !horaValida(h.Inicio) || !horaValida(h.Fim) || h.Inicio >= h.Fim {
+		return fmt.Sprintf("Expediente de %s inválido: use HH:MM com início antes do fim", rotulo)
+	}
+	pausas := make([]models.Pausa, len(h.Pausas))
+	copy(pausas, h.Pausas)
+	sort.Slice(pausas, func(i, j int) bool { return pausas[i].Inicio < pausas[j].Inicio })
+	for i, p := range pausas {
+		if !horaValida(p.Inicio) || !horaValida(p.Fim) || p.Inicio >= p.Fim {
+			return fmt.Sprintf("Pausa de %s inválida: use HH:MM com início antes do fim", rotulo)
+		}
+		if p.Inicio < h.Inicio || p.Fim > h.Fim {
+			return fmt.Sprintf("Pausa de %s fora do expediente", rotulo)
+		}
+		if i > 0 && p.Inicio < pausas[i-1].Fim {
+			return fmt.Sprintf("Pausas de %s se sobrepõem", rotulo)
+		}
+	}
+	return ""
+}
+
+// horaValida aceita apenas "HH:MM" com dois dígitos em cada parte.
+func horaValida(hhmm string) bool {
+	_, err := time.Parse("15:04", hhmm)
+	return err == nil && len(hhmm) == 5
+}
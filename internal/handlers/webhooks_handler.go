@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/emaildoissa/agenda-flow/internal/models"
+	"github.com/emaildoissa/agenda-flow/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+)
+
+// WebhooksHandler gerencia os endpoints de webhook do salão e expõe as entregas.
+type WebhooksHandler struct {
+	DB *sql.DB
+}
+
+// NewWebhooksHandler cria uma nova instância de WebhooksHandler.
+func NewWebhooksHandler(db *sql.DB) *WebhooksHandler {
+	return &WebhooksHandler{DB: db}
+}
+
+// ListFalhas lista os eventos do salão que esgotaram as tentativas de entrega.
+func (h *WebhooksHandler) ListFalhas(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT id, salao_id, evento, payload, status, tentativas, proxima_tentativa_em, ultimo_erro, criado_em, enviado_em
+		FROM webhook_outbox
+		WHERE salao_id = $1 AND status = $2
+		ORDER BY id DESC
+		LIMIT 100`,
+		salaoID, webhooks.StatusFalhou)
+	if err != nil {
+		log.Printf("Erro ao buscar webhooks com falha: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	eventos := make([]models.EventoWebhook, 0)
+	for rows.Next() {
+		var e models.EventoWebhook
+		if err := rows.Scan(&e.ID, &e.SalaoID, &e.Evento, &e.Payload, &e.Status, &e.Tentativas, &e.ProximaTentativaEm, &e.UltimoErro, &e.CriadoEm, &e.EnviadoEm); err != nil {
+			log.Printf("Erro ao escanear webhook: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			return
+		}
+		eventos = append(eventos, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(eventos)
+}
+
+// ReenviarFalha devolve um evento com falha para a fila, zerando as tentativas.
+func (h *WebhooksHandler) ReenviarFalha(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	eventoID, err := strconv.ParseInt(chi.URLParam(r, "idEvento"), 10, 64)
+	if err != nil {
+		http.Error(w, "ID de evento inválido", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.DB.ExecContext(r.Context(), `
+		UPDATE webhook_outbox SET status = $1, tentativas = 0, proxima_tentativa_em = NOW()
+		WHERE id = $2 AND salao_id = $3 AND status = $4`,
+		webhooks.StatusPendente, eventoID, salaoID, webhooks.StatusFalhou)
+	if err != nil {
+		log.Printf("Erro ao reenfileirar webhook: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		log.Printf("Erro ao verificar linhas afetadas: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	if count == 0 {
+		http.Error(w, "Evento com falha não encontrado", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "reenfileirado"})
+}
+
+// CreateEndpoint cadastra um novo endpoint de webhook para o salão.
+// Se o segredo não for informado, um é gerado e devolvido apenas nesta resposta.
+func (h *WebhooksHandler) CreateEndpoint(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req atualizarEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+		return
+	}
+	endpoint := req.WebhookEndpoint
+	if msg := validarEndpoint(endpoint); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if endpoint.Segredo == "" {
+		endpoint.Segredo, err = webhooks.GerarSegredo()
+		if err != nil {
+			log.Printf("Erro ao gerar segredo de webhook: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			return
+		}
+	}
+	if endpoint.Eventos == nil {
+		endpoint.Eventos = []string{}
+	}
+
+	endpoint.SalaoID = salaoID
+	endpoint.Ativo = true
+	err = h.DB.QueryRowContext(r.Context(), `
+		INSERT INTO webhook_endpoints (salao_id, url, segredo, eventos)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, criado_em`,
+		endpoint.SalaoID, endpoint.URL, endpoint.Segredo, endpoint.Eventos).Scan(&endpoint.ID, &endpoint.CriadoEm)
+	if err != nil {
+		log.Printf("Erro ao inserir endpoint de webhook: %v", err)
+		http.Error(w, "Erro ao criar endpoint", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(endpoint)
+}
+
+// ListEndpoints lista os endpoints do salão, sem os segredos.
+func (h *WebhooksHandler) ListEndpoints(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT id, salao_id, url, to_jsonb(eventos), ativo, criado_em
+		FROM webhook_endpoints
+		WHERE salao_id = $1
+		ORDER BY id`,
+		salaoID)
+	if err != nil {
+		log.Printf("Erro ao buscar endpoints de webhook: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	endpoints := make([]models.WebhookEndpoint, 0)
+	for rows.Next() {
+		var e models.WebhookEndpoint
+		var eventosJSON []byte
+		if err := rows.Scan(&e.ID, &e.SalaoID, &e.URL, &eventosJSON, &e.Ativo, &e.CriadoEm); err != nil {
+			log.Printf("Erro ao escanear endpoint de webhook: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			return
+		}
+		if err := json.Unmarshal(eventosJSON, &e.Eventos); err != nil {
+			log.Printf("Erro ao ler eventos do endpoint de webhook %d: %v", e.ID, err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			return
+		}
+		endpoints = append(endpoints, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(endpoints)
+}
+
+// atualizarEndpointRequest é o corpo do PUT de um endpoint. Ativo é ponteiro
+// para distinguir "não enviado" de false.
+type atualizarEndpointRequest struct {
+	models.WebhookEndpoint
+	Ativo *bool `json:"ativo"`
+}
+
+// UpdateEndpoint altera URL, eventos e situação de um endpoint. O segredo e a
+// situação só são trocados se forem enviados.
+func (h *WebhooksHandler) UpdateEndpoint(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	endpointID, err := strconv.Atoi(chi.URLParam(r, "idWebhook"))
+	if err != nil {
+		http.Error(w, "ID de webhook inválido", http.StatusBadRequest)
+		return
+	}
+
+	var req atualizarEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Corpo da requisição inválido", http.StatusBadRequest)
+		return
+	}
+	endpoint := req.WebhookEndpoint
+	if msg := validarEndpoint(endpoint); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if endpoint.Eventos == nil {
+		endpoint.Eventos = []string{}
+	}
+
+	err = h.DB.QueryRowContext(r.Context(), `
+		UPDATE webhook_endpoints
+		SET url = $1, eventos = $2, ativo = COALESCE($3, ativo), segredo = COALESCE(NULLIF($4, ''), segredo)
+		WHERE id = $5 AND salao_id = $6
+		RETURNING id, salao_id, ativo, criado_em`,
+		endpoint.URL, endpoint.Eventos, req.Ativo, endpoint.Segredo, endpointID, salaoID).Scan(&endpoint.ID, &endpoint.SalaoID, &endpoint.Ativo, &endpoint.CriadoEm)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Webhook não encontrado", http.StatusNotFound)
+		} else {
+			log.Printf("Erro ao atualizar endpoint de webhook: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	endpoint.Segredo = ""
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(endpoint)
+}
+
+// DeleteEndpoint desativa o endpoint, preservando o histórico de entregas.
+func (h *WebhooksHandler) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	endpointID, err := strconv.Atoi(chi.URLParam(r, "idWebhook"))
+	if err != nil {
+		http.Error(w, "ID de webhook inválido", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.DB.ExecContext(r.Context(), "UPDATE webhook_endpoints SET ativo = FALSE WHERE id = $1 AND salao_id = $2", endpointID, salaoID)
+	if err != nil {
+		log.Printf("Erro ao desativar endpoint de webhook: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		log.Printf("Erro ao verificar linhas afetadas: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	if count == 0 {
+		http.Error(w, "Webhook não encontrado", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListEntregas mostra o log das últimas tentativas de entrega de um endpoint.
+func (h *WebhooksHandler) ListEntregas(w http.ResponseWriter, r *http.Request) {
+	salaoID, err := salaoDoEscopo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	endpointID, err := strconv.Atoi(chi.URLParam(r, "idWebhook"))
+	if err != nil {
+		http.Error(w, "ID de webhook inválido", http.StatusBadRequest)
+		return
+	}
+
+	var existe bool
+	err = h.DB.QueryRowContext(r.Context(), "SELECT EXISTS (SELECT 1 FROM webhook_endpoints WHERE id = $1 AND salao_id = $2)", endpointID, salaoID).Scan(&existe)
+	if err != nil {
+		log.Printf("Erro ao buscar endpoint de webhook: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	if !existe {
+		http.Error(w, "Webhook não encontrado", http.StatusNotFound)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT en.id, en.outbox_id, o.evento, en.tentativa, en.status_http, en.sucesso, en.erro, en.duracao_ms, en.criado_em
+		FROM webhook_entregas en
+		JOIN webhook_outbox o ON o.id = en.outbox_id
+		WHERE en.endpoint_id = $1
+		ORDER BY en.id DESC
+		LIMIT 100`,
+		endpointID)
+	if err != nil {
+		log.Printf("Erro ao buscar entregas de webhook: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	entregas := make([]models.EntregaWebhook, 0)
+	for rows.Next() {
+		var e models.EntregaWebhook
+		if err := rows.Scan(&e.ID, &e.OutboxID, &e.Evento, &e.Tentativa, &e.StatusHTTP, &e.Sucesso, &e.Erro, &e.DuracaoMs, &e.CriadoEm); err != nil {
+			log.Printf("Erro ao escanear entrega de webhook: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+			return
+		}
+		entregas = append(entregas, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entregas)
+}
+
+// validarEndpoint confere a URL e os eventos assinados. Devolve "" se estiver tudo certo.
+func validarEndpoint(endpoint models.WebhookEndpoint) string {
+	u, err := url.Parse(endpoint.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "URL do webhook inválida"
+	}
+	for _, evento := range endpoint.Eventos {
+		if !slices.Contains(webhooks.EventosValidos, evento) {
+			return "Evento desconhecido: " + evento
+		}
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// HorarioDia é o expediente de um dia da semana, com horários no formato "HH:MM".
+// É usado tanto nos horários de funcionamento do salão quanto nos de cada profissional.
+type HorarioDia struct {
+	Inicio string  `json:"inicio"`
+	Fim    string  `json:"fim"`
+	Pausas []Pausa `json:"pausas"`
+}
+
+type Pausa struct {
+	Inicio string `json:"inicio"`
+	Fim    string `json:"fim"`
+}
+
+// HorarioSemanal mapeia o dia da semana ("segunda", "terca", ..., "domingo")
+// para o expediente daquele dia. Dias ausentes são dias sem atendimento.
+type HorarioSemanal map[string]*HorarioDia
+
+// Value grava o horário como JSON (JSONB no banco); um horário nulo vira NULL.
+func (h HorarioSemanal) Value() (driver.Value, error) {
+	if h == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan lê o horário de uma coluna JSONB; NULL resulta num horário nulo.
+func (h *HorarioSemanal) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*h = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("tipo %T não pode ser lido como horário semanal", src)
+	}
+	return json.Unmarshal(b, h)
+}
+
+// Value grava o expediente de um dia como JSON (JSONB no banco).
+func (h HorarioDia) Value() (driver.Value, error) {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan lê o expediente de um dia de uma coluna JSONB.
+func (h *HorarioDia) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, h)
+	case string:
+		return json.Unmarshal([]byte(v), h)
+	default:
+		return fmt.Errorf("tipo %T não pode ser lido como horário", src)
+	}
+}
+
+// ExcecaoHorario muda o expediente de uma data específica: o salão (ou um
+// profissional) fecha o dia todo ou atende num horário diferente do semanal.
+type ExcecaoHorario struct {
+	ID            int         `json:"id"`
+	SalaoID       int         `json:"salao_id"`
+	FuncionarioID *int        `json:"funcionario_id"` // Nulo = vale para o salão todo
+	Data          string      `json:"data"`           // AAAA-MM-DD, no calendário local do salão
+	Fechado       bool        `json:"fechado"`
+	Horario       *HorarioDia `json:"horario,omitempty"` // Expediente especial, quando não está fechado
+	Descricao     string      `json:"descricao"`
+}
+
+// HorarioDoDia devolve o expediente que a exceção define, ou nil se o dia está fechado.
+func (e ExcecaoHorario) HorarioDoDia() *HorarioDia {
+	if e.Fechado {
+		return nil
+	}
+	return e.Horario
+}
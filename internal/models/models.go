@@ -1,17 +1,19 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
 type Salao struct {
-	ID                    int       `json:"id"`
-	NomeSalao             string    `json:"nome_salao"`
-	EmailProprietario     string    `json:"email_proprietario"`
-	HashSenha             string    `json:"senha"`
-	WhatsappNotificacao   string    `json:"whatsapp_notificacao"`
-	HorariosFuncionamento []byte    `json:"horarios_funcionamento"` // Representado como JSON raw
-	CriadoEm              time.Time `json:"criado_em"`
+	ID                    int            `json:"id"`
+	NomeSalao             string         `json:"nome_salao"`
+	EmailProprietario     string         `json:"email_proprietario"`
+	HashSenha             string         `json:"senha"`
+	WhatsappNotificacao   string         `json:"whatsapp_notificacao"`
+	HorariosFuncionamento HorarioSemanal `json:"horarios_funcionamento"`
+	FusoHorario           string         `json:"fuso_horario"` // Nome IANA, ex.: "America/Sao_Paulo"
+	CriadoEm              time.Time      `json:"criado_em"`
 }
 
 type Servico struct {
@@ -21,21 +23,194 @@ type Servico struct {
 	DuracaoMinutos int     `json:"duracao_minutos"`
 	Preco          float64 `json:"preco"`
 	Ativo          bool    `json:"ativo"`
+	Ordem          int     `json:"ordem"` // Posição na página de agendamento (menor primeiro)
+
+	// IntervaloSlotsMinutos substitui o intervalo do salão para este serviço (nulo = o do salão).
+	IntervaloSlotsMinutos *int `json:"intervalo_slots_minutos"`
+	// Preparação antes e limpeza depois: bloqueiam a agenda sem entrar na duração cobrada.
+	BufferAntesMinutos  int `json:"buffer_antes_minutos"`
+	BufferDepoisMinutos int `json:"buffer_depois_minutos"`
 }
 
 type Agendamento struct {
-	ID             int       `json:"id"`
-	SalaoID        int       `json:"salao_id"`
-	ServicoID      int       `json:"servico_id"`
-	ClienteNome    string    `json:"cliente_nome"`
-	ClienteContato string    `json:"cliente_contato"`
-	DataHoraInicio time.Time `json:"data_hora_inicio"`
-	DataHoraFim    time.Time `json:"data_hora_fim"`
-	Status         string    `json:"status"`
-	CriadoEm       time.Time `json:"criado_em"`
+	ID             int        `json:"id"`
+	SalaoID        int        `json:"salao_id"`
+	ServicoID      int        `json:"servico_id"`
+	FuncionarioID  *int       `json:"funcionario_id"` // Opcional: nulo quando o salão não trabalha com profissionais
+	ClienteNome    string     `json:"cliente_nome"`
+	ClienteContato string     `json:"cliente_contato"`
+	DataHoraInicio time.Time  `json:"data_hora_inicio"`
+	DataHoraFim    time.Time  `json:"data_hora_fim"`
+	Status         string     `json:"status"`
+	ExpiraEm       *time.Time `json:"expira_em,omitempty"`    // Só em pré-reservas PENDENTE
+	TokenGestao    string     `json:"token_gestao,omitempty"` // Dá ao cliente acesso ao próprio agendamento
+	Reagendamentos int        `json:"reagendamentos"`
+	CriadoEm       time.Time  `json:"criado_em"`
+
+	// Servicos são os serviços da visita, na ordem em que são feitos. Na
+	// criação, pode substituir servico_id e funcionario_id para pedir vários
+	// serviços, cada um com um profissional opcional.
+	Servicos   []AgendamentoServico `json:"servicos,omitempty"`
+	PrecoTotal float64              `json:"preco_total"`
+
+	// Trecho da agenda ocupado, incluindo os buffers do serviço
+	BloqueioInicio time.Time `json:"-"`
+	BloqueioFim    time.Time `json:"-"`
 }
+
+// AgendamentoServico é um dos serviços de um agendamento. Os serviços da visita
+// são feitos em sequência, cada um logo após o anterior.
+type AgendamentoServico struct {
+	Posicao         int       `json:"posicao"`
+	ServicoID       int       `json:"servico_id"`
+	ServicoNome     string    `json:"servico_nome,omitempty"`
+	FuncionarioID   *int      `json:"funcionario_id"`
+	FuncionarioNome *string   `json:"funcionario_nome,omitempty"`
+	DataHoraInicio  time.Time `json:"data_hora_inicio"`
+	DataHoraFim     time.Time `json:"data_hora_fim"`
+	Preco           float64   `json:"preco"` // Valor cobrado no momento da reserva
+
+	// Trecho da agenda do profissional ocupado, incluindo os buffers
+	BloqueioInicio time.Time `json:"-"`
+	BloqueioFim    time.Time `json:"-"`
+}
+
 type Funcionario struct {
-	ID    int    `json:"id"`
-	Nome  string `json:"nome"`
-	Ativo bool   `json:"ativo"`
+	ID      int    `json:"id"`
+	SalaoID int    `json:"salao_id"`
+	Nome    string `json:"nome"`
+	Ativo   bool   `json:"ativo"`
+	// Horarios é o turno do profissional; nulo significa que ele segue o
+	// expediente do salão. Em cada dia vale o trecho comum aos dois.
+	Horarios HorarioSemanal `json:"horarios"`
+}
+
+// FuncionarioServico indica que o profissional realiza o serviço. Duração e
+// preço nulos seguem os valores do próprio serviço.
+type FuncionarioServico struct {
+	FuncionarioID  int      `json:"funcionario_id"`
+	ServicoID      int      `json:"servico_id"`
+	ServicoNome    string   `json:"servico_nome,omitempty"`
+	DuracaoMinutos *int     `json:"duracao_minutos"`
+	Preco          *float64 `json:"preco"`
+}
+
+// EventoWebhook é uma notificação gravada na outbox, aguardando (ou já após) a entrega.
+type EventoWebhook struct {
+	ID                 int64           `json:"id"`
+	SalaoID            int             `json:"salao_id"`
+	Evento             string          `json:"evento"`
+	Payload            json.RawMessage `json:"payload"`
+	Status             string          `json:"status"`
+	Tentativas         int             `json:"tentativas"`
+	ProximaTentativaEm time.Time       `json:"proxima_tentativa_em"`
+	UltimoErro         *string         `json:"ultimo_erro"`
+	CriadoEm           time.Time       `json:"criado_em"`
+	EnviadoEm          *time.Time      `json:"enviado_em"`
+}
+
+// WebhookEndpoint é um destino de webhooks cadastrado por um salão.
+type WebhookEndpoint struct {
+	ID       int       `json:"id"`
+	SalaoID  int       `json:"salao_id"`
+	URL      string    `json:"url"`
+	Segredo  string    `json:"segredo,omitempty"` // Só é devolvido na criação
+	Eventos  []string  `json:"eventos"`           // Vazio significa todos os eventos
+	Ativo    bool      `json:"ativo"`
+	CriadoEm time.Time `json:"criado_em"`
+}
+
+// EntregaWebhook registra uma tentativa de entrega de um evento.
+type EntregaWebhook struct {
+	ID         int64     `json:"id"`
+	OutboxID   int64     `json:"outbox_id"`
+	Evento     string    `json:"evento"`
+	Tentativa  int       `json:"tentativa"`
+	StatusHTTP *int      `json:"status_http"`
+	Sucesso    bool      `json:"sucesso"`
+	Erro       *string   `json:"erro"`
+	DuracaoMs  int64     `json:"duracao_ms"`
+	CriadoEm   time.Time `json:"criado_em"`
+}
+
+// HistoricoAgendamento registra uma mudança de status (ou de horário) de um agendamento.
+type HistoricoAgendamento struct {
+	ID             int64     `json:"id"`
+	AgendamentoID  int       `json:"agendamento_id"`
+	StatusAnterior *string   `json:"status_anterior"` // Nulo na criação
+	StatusNovo     string    `json:"status_novo"`
+	Motivo         *string   `json:"motivo"`
+	AlteradoPor    string    `json:"alterado_por"`
+	AlteradoEm     time.Time `json:"alterado_em"`
+}
+
+// Modos de confirmação de novos agendamentos
+const (
+	ModoConfirmacaoAutomatico = "AUTOMATICO"
+	ModoConfirmacaoManual     = "MANUAL"
+)
+
+// ConfiguracoesSalao reúne as regras de agendamento ajustáveis por cada salão.
+type ConfiguracoesSalao struct {
+	ModoConfirmacao        string `json:"modo_confirmacao"`         // AUTOMATICO ou MANUAL
+	MinutosReservaPendente int    `json:"minutos_reserva_pendente"` // Quanto tempo uma pré-reserva segura o horário
+
+	// Política de cancelamento: antecedência mínima para o cliente cancelar ou
+	// reagendar sozinho, e quantas vezes o mesmo agendamento pode ser reagendado.
+	AntecedenciaMinimaCancelamentoHoras int `json:"antecedencia_minima_cancelamento_horas"`
+	MaxReagendamentos                   int `json:"max_reagendamentos"`
+
+	// Antecedências, em minutos, dos lembretes enviados antes de cada agendamento
+	// (ex.: [1440, 120] para 24h e 2h antes). Lista vazia desliga os lembretes.
+	LembretesMinutos []int `json:"lembretes_minutos"`
+
+	// Granularidade dos horários oferecidos (10, 15, 20 ou 30 minutos) e, com
+	// MinimizarLacunas, só horários encostados em agendamentos ou no expediente.
+	IntervaloSlotsMinutos int  `json:"intervalo_slots_minutos"`
+	MinimizarLacunas      bool `json:"minimizar_lacunas"`
+
+	// Janela de reservas: antecedência mínima para marcar e até quantos dias
+	// à frente a agenda fica aberta (hoje é o dia zero).
+	AntecedenciaMinimaReservaMinutos int `json:"antecedencia_minima_reserva_minutos"`
+	HorizonteReservaDias             int `json:"horizonte_reserva_dias"`
+}
+
+// CancelamentosTardiosCliente resume os cancelamentos tardios de um cliente.
+type CancelamentosTardiosCliente struct {
+	ClienteContato       string    `json:"cliente_contato"`
+	ClienteNome          string    `json:"cliente_nome"`
+	CancelamentosTardios int       `json:"cancelamentos_tardios"`
+	UltimoAgendamentoEm  time.Time `json:"ultimo_agendamento_em"`
+}
+
+// PaginaAgendamentos é uma página da listagem de agendamentos do salão.
+// ProximoCursor vem vazio na última página.
+type PaginaAgendamentos struct {
+	Agendamentos  []Agendamento `json:"agendamentos"`
+	ProximoCursor string        `json:"proximo_cursor,omitempty"`
+}
+
+// AgendaDoDia é a visão compacta de um dia do salão, com uma coluna por profissional.
+type AgendaDoDia struct {
+	Data          string               `json:"data"`
+	Profissionais []AgendaProfissional `json:"profissionais"`
+}
+
+// AgendaProfissional são os atendimentos de um profissional no dia. Os
+// atendimentos sem profissional ficam numa coluna com FuncionarioID nulo.
+type AgendaProfissional struct {
+	FuncionarioID *int               `json:"funcionario_id"`
+	Nome          string             `json:"nome"`
+	Atendimentos  []AtendimentoDoDia `json:"atendimentos"`
+}
+
+// AtendimentoDoDia é um serviço na agenda do dia, com os horários já no fuso do salão.
+type AtendimentoDoDia struct {
+	AgendamentoID  int    `json:"agendamento_id"`
+	Inicio         string `json:"inicio"` // HH:MM
+	Fim            string `json:"fim"`    // HH:MM
+	ServicoNome    string `json:"servico_nome"`
+	ClienteNome    string `json:"cliente_nome"`
+	ClienteContato string `json:"cliente_contato"`
+	Status         string `json:"status"`
 }
@@ -0,0 +1,33 @@
+package models
+
+// Status possíveis de um agendamento
+const (
+	StatusPendente      = "PENDENTE"
+	StatusConfirmado    = "CONFIRMADO"
+	StatusConcluido     = "CONCLUIDO"
+	StatusNaoCompareceu = "NAO_COMPARECEU"
+	StatusCancelado     = "CANCELADO"
+)
+
+// transicoesPermitidas é a máquina de estados do agendamento:
+// PENDENTE → CONFIRMADO → CONCLUIDO / NAO_COMPARECEU, e qualquer status
+// ainda em aberto → CANCELADO. CONCLUIDO, NAO_COMPARECEU e CANCELADO são finais.
+var transicoesPermitidas = map[string][]string{
+	StatusPendente:   {StatusConfirmado, StatusCancelado},
+	StatusConfirmado: {StatusConcluido, StatusNaoCompareceu, StatusCancelado},
+}
+
+// PodeTransicionar diz se um agendamento pode passar do status de para o status para.
+func PodeTransicionar(de, para string) bool {
+	for _, permitido := range transicoesPermitidas[de] {
+		if permitido == para {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusAtivo diz se o agendamento ainda ocupa a agenda.
+func StatusAtivo(status string) bool {
+	return status == StatusPendente || status == StatusConfirmado
+}
@@ -0,0 +1,40 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Cabeçalhos enviados em toda entrega
+const (
+	CabecalhoAssinatura = "X-AgendaFlow-Assinatura"
+	CabecalhoEvento     = "X-AgendaFlow-Evento"
+	CabecalhoEntrega    = "X-AgendaFlow-Entrega"
+)
+
+// Assinar calcula o valor do cabeçalho X-AgendaFlow-Assinatura no formato
+// "t=<unix>,v1=<hex>", onde v1 é o HMAC-SHA256 de "<unix>.<corpo>" com o segredo
+// do endpoint. O receptor deve recalcular o HMAC e rejeitar timestamps antigos
+// para evitar replays.
+func Assinar(segredo string, corpo []byte, momento time.Time) string {
+	t := strconv.FormatInt(momento.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(segredo))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(corpo)
+	return fmt.Sprintf("t=%s,v1=%s", t, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// GerarSegredo cria um segredo aleatório para um novo endpoint.
+func GerarSegredo() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
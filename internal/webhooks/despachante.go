@@ -0,0 +1,240 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Despachante lê a outbox periodicamente e entrega os eventos aos endpoints
+// cadastrados, com backoff exponencial entre as tentativas.
+type Despachante struct {
+	DB            *sql.DB
+	URL           string // URL global do n8n, usada por salões sem endpoints próprios
+	Segredo       string // Segredo da URL global; se vazio, essas entregas não são assinadas
+	Cliente       *http.Client
+	Intervalo     time.Duration // Frequência de leitura da outbox
+	TamanhoLote   int
+	MaxTentativas int           // Depois disso o evento vai para FALHOU
+	BackoffBase   time.Duration // Espera após a primeira falha; dobra a cada nova falha
+	BackoffMaximo time.Duration
+
+	// Reserva é por quanto tempo um lote lido fica fora do alcance das outras
+	// réplicas. Precisa cobrir o envio do lote inteiro; se a réplica cair no
+	// meio, os eventos voltam a ser entregues depois desse prazo.
+	Reserva time.Duration
+}
+
+// NewDespachante cria um despachante com valores padrão razoáveis.
+func NewDespachante(db *sql.DB, url, segredo string) *Despachante {
+	return &Despachante{
+		DB:            db,
+		URL:           url,
+		Segredo:       segredo,
+		Cliente:       &http.Client{Timeout: 10 * time.Second},
+		Intervalo:     5 * time.Second,
+		TamanhoLote:   20,
+		MaxTentativas: 8,
+		BackoffBase:   30 * time.Second,
+		BackoffMaximo: time.Hour,
+		Reserva:       5 * time.Minute,
+	}
+}
+
+// Iniciar processa a outbox até o contexto ser cancelado.
+func (d *Despachante) Iniciar(ctx context.Context) {
+	if d.URL == "" {
+		log.Println("AVISO: URL do webhook do n8n não configurada. Eventos de salões sem endpoints próprios ficarão na outbox até que seja definida.")
+	}
+
+	ticker := time.NewTicker(d.Intervalo)
+	defer ticker.Stop()
+	for {
+		if err := d.processarLote(ctx); err != nil {
+			log.Printf("Erro ao processar a outbox de webhooks: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type eventoPendente struct {
+	id            int64
+	endpointID    *int
+	evento        string
+	payload       []byte
+	tentativas    int
+	url           string
+	segredo       string
+	endpointAtivo bool
+}
+
+// processarLote entrega um lote de eventos vencidos. Os eventos são reservados
+// num comando curto, que adia proxima_tentativa_em pelo tempo da Reserva; o FOR
+// UPDATE SKIP LOCKED e a reserva permitem rodar várias réplicas da API sem que
+// um evento seja enviado duas vezes. Os envios acontecem fora de qualquer
+// transação, e o resultado de cada um é gravado na sua própria transação.
+func (d *Despachante) processarLote(ctx context.Context) error {
+	eventos, err := d.reservarLote(ctx)
+	if err != nil {
+		return err
+	}
+	for _, e := range eventos {
+		if err := d.entregar(ctx, e); err != nil {
+			// O evento continua reservado e volta a ser tentado quando a reserva vencer
+			log.Printf("Erro ao registrar a entrega do evento %d (%s): %v", e.id, e.evento, err)
+		}
+	}
+	return nil
+}
+
+// reservarLote marca um lote de eventos vencidos como em andamento e devolve
+// os dados necessários para enviá-los.
+func (d *Despachante) reservarLote(ctx context.Context) ([]eventoPendente, error) {
+	// Entregas para a URL global só são lidas se ela estiver configurada
+	rows, err := d.DB.QueryContext(ctx, `
+		WITH reservados AS (
+			SELECT o.id, COALESCE(e.url, '') AS url, COALESCE(e.segredo, '') AS segredo, COALESCE(e.ativo, TRUE) AS ativo
+			FROM webhook_outbox o
+			LEFT JOIN webhook_endpoints e ON e.id = o.endpoint_id
+			WHERE o.status = $1 AND o.proxima_tentativa_em <= NOW()
+			  AND (o.endpoint_id IS NOT NULL OR $3)
+			ORDER BY o.id
+			LIMIT $2
+			FOR UPDATE OF o SKIP LOCKED
+		)
+		UPDATE webhook_outbox o SET proxima_tentativa_em = NOW() + $4 * INTERVAL '1 second'
+		FROM reservados r
+		WHERE o.id = r.id
+		RETURNING o.id, o.endpoint_id, o.evento, o.payload, o.tentativas, r.url, r.segredo, r.ativo`,
+		StatusPendente, d.TamanhoLote, d.URL != "", d.Reserva.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var eventos []eventoPendente
+	for rows.Next() {
+		var e eventoPendente
+		if err := rows.Scan(&e.id, &e.endpointID, &e.evento, &e.payload, &e.tentativas, &e.url, &e.segredo, &e.endpointAtivo); err != nil {
+			return nil, err
+		}
+		if e.endpointID == nil {
+			e.url, e.segredo = d.URL, d.Segredo
+		}
+		eventos = append(eventos, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING não garante ordem; entregamos na ordem em que os eventos entraram
+	sort.Slice(eventos, func(i, j int) bool { return eventos[i].id < eventos[j].id })
+	return eventos, nil
+}
+
+// entregar faz uma tentativa de envio e, numa transação só dele, registra o
+// resultado no log de entregas e atualiza o evento na outbox.
+func (d *Despachante) entregar(ctx context.Context, e eventoPendente) error {
+	tentativas := e.tentativas + 1
+	inicio := time.Now()
+
+	var statusHTTP *int
+	var errEnvio error
+	if e.endpointAtivo {
+		statusHTTP, errEnvio = d.enviar(ctx, e)
+	} else {
+		// O endpoint foi desativado depois que o evento entrou na fila
+		errEnvio = fmt.Errorf("endpoint desativado")
+		tentativas = d.MaxTentativas
+	}
+	duracao := time.Since(inicio)
+
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var msgErro *string
+	if errEnvio != nil {
+		msg := errEnvio.Error()
+		msgErro = &msg
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO webhook_entregas (outbox_id, endpoint_id, tentativa, status_http, sucesso, erro, duracao_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		e.id, e.endpointID, tentativas, statusHTTP, errEnvio == nil, msgErro, duracao.Milliseconds())
+	if err != nil {
+		return err
+	}
+
+	if errEnvio == nil {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE webhook_outbox SET status = $1, tentativas = $2, enviado_em = NOW(), ultimo_erro = NULL
+			WHERE id = $3`,
+			StatusEnviado, tentativas, e.id)
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	status := StatusPendente
+	if tentativas >= d.MaxTentativas {
+		status = StatusFalhou
+		log.Printf("Evento %d (%s) esgotou %d tentativas e foi para a fila de falhas: %v", e.id, e.evento, tentativas, errEnvio)
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE webhook_outbox SET status = $1, tentativas = $2, ultimo_erro = $3, proxima_tentativa_em = $4
+		WHERE id = $5`,
+		status, tentativas, errEnvio.Error(), time.Now().Add(d.backoff(tentativas)), e.id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// backoff devolve a espera antes da próxima tentativa: base, 2x base, 4x base...
+func (d *Despachante) backoff(tentativas int) time.Duration {
+	espera := d.BackoffBase
+	for i := 1; i < tentativas && espera < d.BackoffMaximo; i++ {
+		espera *= 2
+	}
+	return min(espera, d.BackoffMaximo)
+}
+
+func (d *Despachante) enviar(ctx context.Context, e eventoPendente) (*int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", e.url, bytes.NewReader(e.payload))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CabecalhoEvento, e.evento)
+	req.Header.Set(CabecalhoEntrega, strconv.FormatInt(e.id, 10))
+	if e.segredo != "" {
+		req.Header.Set(CabecalhoAssinatura, Assinar(e.segredo, e.payload, time.Now()))
+	}
+
+	resp, err := d.Cliente.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao enviar webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 400 {
+		return &resp.StatusCode, fmt.Errorf("endpoint respondeu com status %s", resp.Status)
+	}
+	return &resp.StatusCode, nil
+}
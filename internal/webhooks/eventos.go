@@ -0,0 +1,192 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Tipos de evento do ciclo de vida de um agendamento
+const (
+	EventoAgendamentoCriado        = "agendamento.criado"
+	EventoAgendamentoConfirmado    = "agendamento.confirmado"
+	EventoAgendamentoCancelado     = "agendamento.cancelado"
+	EventoAgendamentoReagendado    = "agendamento.reagendado"
+	EventoAgendamentoConcluido     = "agendamento.concluido"
+	EventoAgendamentoNaoCompareceu = "agendamento.nao_compareceu"
+	EventoAgendamentoExpirado      = "agendamento.expirado" // Pré-reserva cancelada por falta de confirmação
+	EventoAgendamentoLembrete      = "agendamento.lembrete" // Enviado nas antecedências configuradas no salão
+)
+
+// EventosValidos lista os eventos que um endpoint pode assinar.
+var EventosValidos = []string{
+	EventoAgendamentoCriado,
+	EventoAgendamentoConfirmado,
+	EventoAgendamentoCancelado,
+	EventoAgendamentoReagendado,
+	EventoAgendamentoConcluido,
+	EventoAgendamentoNaoCompareceu,
+	EventoAgendamentoExpirado,
+	EventoAgendamentoLembrete,
+}
+
+// VersaoPayload deve ser incrementada sempre que um campo mudar de significado
+// ou for removido. Campos novos podem ser adicionados sem trocar a versão.
+const VersaoPayload = 1
+
+// N8NPayload é a estrutura de dados que enviaremos para o n8n
+type N8NPayload struct {
+	AgendamentoID       int    `json:"agendamento_id"`
+	ClienteNome         string `json:"cliente_nome"`
+	ServicoNome         string `json:"servico_nome"`
+	DataHoraFormatada   string `json:"data_hora_formatada"`
+	WhatsappNotificacao string `json:"whatsapp_notificacao"`
+}
+
+// PayloadEvento estende o N8NPayload com o tipo do evento e os dados de que o
+// fluxo precisa para avisar o cliente. Os campos antigos continuam no mesmo
+// nível do JSON, então os fluxos já existentes no n8n seguem funcionando.
+type PayloadEvento struct {
+	Evento     string    `json:"evento"`
+	Versao     int       `json:"versao"`
+	OcorridoEm time.Time `json:"ocorrido_em"`
+	N8NPayload
+	SalaoID                   int       `json:"salao_id"`
+	Status                    string    `json:"status"`
+	ClienteContato            string    `json:"cliente_contato"`
+	FuncionarioID             *int      `json:"funcionario_id"`
+	DataHoraInicio            time.Time `json:"data_hora_inicio"`
+	DataHoraFim               time.Time `json:"data_hora_fim"`
+	DataHoraAnteriorFormatada string    `json:"data_hora_anterior_formatada,omitempty"` // Só em agendamento.reagendado
+	LinkGestao                string    `json:"link_gestao,omitempty"`
+	AntecedenciaMinutos       int       `json:"antecedencia_minutos,omitempty"` // Só em agendamento.lembrete
+
+	// Servicos lista os serviços da visita na ordem em que são feitos; servico_nome
+	// traz os nomes unidos por " + " para os fluxos que só leem ele.
+	Servicos   []ServicoDoEvento `json:"servicos"`
+	PrecoTotal float64           `json:"preco_total"`
+}
+
+// ServicoDoEvento é um dos serviços do agendamento no payload.
+type ServicoDoEvento struct {
+	ServicoID       int       `json:"servico_id"`
+	ServicoNome     string    `json:"servico_nome"`
+	FuncionarioID   *int      `json:"funcionario_id"`
+	FuncionarioNome *string   `json:"funcionario_nome"`
+	DataHoraInicio  time.Time `json:"data_hora_inicio"`
+	DataHoraFim     time.Time `json:"data_hora_fim"`
+	Preco           float64   `json:"preco"`
+}
+
+// Notificador grava na outbox os eventos dos agendamentos.
+type Notificador struct {
+	// LinkGestaoBase é o início do link que o cliente recebe para ver, cancelar
+	// ou reagendar o próprio agendamento; o token de gestão é concatenado ao
+	// final. Vazio, os eventos saem sem link_gestao.
+	LinkGestaoBase string
+}
+
+// NewNotificador cria um notificador que monta os links de gestão a partir de linkGestaoBase.
+func NewNotificador(linkGestaoBase string) *Notificador {
+	return &Notificador{LinkGestaoBase: linkGestaoBase}
+}
+
+// NotificarAgendamento monta o payload a partir do estado atual do agendamento
+// e o grava na outbox, dentro da transação que fez a alteração.
+// inicioAnterior só é usado em reagendamentos.
+func (n *Notificador) NotificarAgendamento(ctx context.Context, tx *sql.Tx, evento string, agendamentoID int, inicioAnterior *time.Time) error {
+	p, err := n.montarPayload(ctx, tx, evento, agendamentoID, inicioAnterior)
+	if err != nil {
+		return err
+	}
+	return Enfileirar(ctx, tx, p.SalaoID, evento, p)
+}
+
+// NotificarLembrete enfileira o lembrete enviado antecedenciaMinutos antes do
+// início do agendamento.
+func (n *Notificador) NotificarLembrete(ctx context.Context, tx *sql.Tx, agendamentoID, antecedenciaMinutos int) error {
+	p, err := n.montarPayload(ctx, tx, EventoAgendamentoLembrete, agendamentoID, nil)
+	if err != nil {
+		return err
+	}
+	p.AntecedenciaMinutos = antecedenciaMinutos
+	return Enfileirar(ctx, tx, p.SalaoID, EventoAgendamentoLembrete, p)
+}
+
+// montarPayload carrega o estado atual do agendamento no formato do evento.
+func (n *Notificador) montarPayload(ctx context.Context, tx *sql.Tx, evento string, agendamentoID int, inicioAnterior *time.Time) (PayloadEvento, error) {
+	p := PayloadEvento{Evento: evento, Versao: VersaoPayload, OcorridoEm: time.Now().UTC()}
+	var fusoHorario, tokenGestao string
+	err := tx.QueryRowContext(ctx, `
+		SELECT a.id, a.salao_id, a.status, a.cliente_nome, a.cliente_contato, a.funcionario_id,
+		       a.data_hora_inicio, a.data_hora_fim, COALESCE(a.token_gestao, ''), s.nome, sa.whatsapp_notificacao, sa.fuso_horario
+		FROM agendamentos a
+		JOIN servicos s ON s.id = a.servico_id
+		JOIN saloes sa ON sa.id = a.salao_id
+		WHERE a.id = $1`,
+		agendamentoID).Scan(
+		&p.AgendamentoID, &p.SalaoID, &p.Status, &p.ClienteNome, &p.ClienteContato, &p.FuncionarioID,
+		&p.DataHoraInicio, &p.DataHoraFim, &tokenGestao, &p.ServicoNome, &p.WhatsappNotificacao, &fusoHorario,
+	)
+	if err != nil {
+		return p, fmt.Errorf("erro ao carregar agendamento %d para o evento %s: %w", agendamentoID, evento, err)
+	}
+
+	p.Servicos, err = carregarServicos(ctx, tx, agendamentoID)
+	if err != nil {
+		return p, fmt.Errorf("erro ao carregar serviços do agendamento %d para o evento %s: %w", agendamentoID, evento, err)
+	}
+	if len(p.Servicos) > 0 {
+		nomes := make([]string, 0, len(p.Servicos))
+		for _, s := range p.Servicos {
+			nomes = append(nomes, s.ServicoNome)
+			p.PrecoTotal += s.Preco
+		}
+		p.ServicoNome = strings.Join(nomes, " + ")
+	}
+
+	// O horário da mensagem sai no fuso do salão
+	loc, err := time.LoadLocation(fusoHorario)
+	if err != nil {
+		log.Printf("Fuso horário inválido '%s' no salão %d, usando UTC: %v", fusoHorario, p.SalaoID, err)
+		loc = time.UTC
+	}
+	p.DataHoraFormatada = p.DataHoraInicio.In(loc).Format("15:04 de 02/01/2006")
+	if inicioAnterior != nil {
+		p.DataHoraAnteriorFormatada = inicioAnterior.In(loc).Format("15:04 de 02/01/2006")
+	}
+
+	if n.LinkGestaoBase != "" && tokenGestao != "" {
+		p.LinkGestao = n.LinkGestaoBase + tokenGestao
+	}
+	return p, nil
+}
+
+// carregarServicos lê os serviços do agendamento, na ordem da visita.
+func carregarServicos(ctx context.Context, tx *sql.Tx, agendamentoID int) ([]ServicoDoEvento, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT ags.servico_id, s.nome, ags.funcionario_id, f.nome, ags.data_hora_inicio, ags.data_hora_fim, ags.preco
+		FROM agendamento_servicos ags
+		JOIN servicos s ON s.id = ags.servico_id
+		LEFT JOIN funcionarios f ON f.id = ags.funcionario_id
+		WHERE ags.agendamento_id = $1
+		ORDER BY ags.posicao`,
+		agendamentoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	servicos := []ServicoDoEvento{}
+	for rows.Next() {
+		var s ServicoDoEvento
+		if err := rows.Scan(&s.ServicoID, &s.ServicoNome, &s.FuncionarioID, &s.FuncionarioNome, &s.DataHoraInicio, &s.DataHoraFim, &s.Preco); err != nil {
+			return nil, err
+		}
+		servicos = append(servicos, s)
+	}
+	return servicos, rows.Err()
+}
@@ -0,0 +1,52 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// Status possíveis de um evento na outbox
+const (
+	StatusPendente = "PENDENTE"
+	StatusEnviado  = "ENVIADO"
+	StatusFalhou   = "FALHOU" // Esgotou as tentativas (dead letter)
+)
+
+// Enfileirar grava o evento na outbox usando a transação de quem o gerou.
+// Assim o evento só existe se a alteração no agendamento também for confirmada,
+// e nenhum evento se perde se a API reiniciar antes do envio.
+//
+// É criada uma entrega para cada endpoint ativo do salão que assina o evento
+// (um endpoint sem eventos assina todos). Salões sem nenhum endpoint cadastrado
+// continuam usando a URL global do n8n (endpoint_id nulo).
+func Enfileirar(ctx context.Context, tx *sql.Tx, salaoID int, evento string, payload any) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("erro ao serializar payload do evento %s: %w", evento, err)
+	}
+
+	var temEndpoints bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM webhook_endpoints WHERE salao_id = $1 AND ativo = TRUE)", salaoID).Scan(&temEndpoints)
+	if err != nil {
+		return fmt.Errorf("erro ao buscar endpoints do salão %d: %w", salaoID, err)
+	}
+
+	if temEndpoints {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO webhook_outbox (salao_id, endpoint_id, evento, payload)
+			SELECT $1, id, $2, $3 FROM webhook_endpoints
+			WHERE salao_id = $1 AND ativo = TRUE AND (cardinality(eventos) = 0 OR $2 = ANY(eventos))`,
+			salaoID, evento, payloadBytes)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO webhook_outbox (salao_id, evento, payload)
+			VALUES ($1, $2, $3)`,
+			salaoID, evento, payloadBytes)
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao gravar evento %s na outbox: %w", evento, err)
+	}
+	return nil
+}
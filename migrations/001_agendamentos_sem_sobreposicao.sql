@@ -0,0 +1,9 @@
+-- Impede que dois agendamentos ativos do mesmo salão ocupem o mesmo intervalo.
+-- A verificação acontece no banco, então vale mesmo com requisições simultâneas.
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+ALTER TABLE agendamentos ADD CONSTRAINT agendamentos_sem_sobreposicao
+    EXCLUDE USING gist (
+        salao_id WITH =,
+        tstzrange(data_hora_inicio, data_hora_fim) WITH &&
+    ) WHERE (status IN ('PENDENTE', 'CONFIRMADO'));
@@ -0,0 +1,14 @@
+-- Agendamentos podem ser atribuídos a um profissional. A proteção contra
+-- sobreposição passa a valer por agenda: cada funcionário tem a sua, e os
+-- agendamentos sem profissional (funcionario_id nulo) compartilham uma única agenda.
+ALTER TABLE agendamentos ADD COLUMN funcionario_id INTEGER;
+
+ALTER TABLE agendamentos DROP CONSTRAINT agendamentos_sem_sobreposicao;
+ALTER TABLE agendamentos ADD CONSTRAINT agendamentos_sem_sobreposicao
+    EXCLUDE USING gist (
+        salao_id WITH =,
+        (COALESCE(funcionario_id, 0)) WITH =,
+        tstzrange(data_hora_inicio, data_hora_fim) WITH &&
+    ) WHERE (status IN ('PENDENTE', 'CONFIRMADO'));
+
+CREATE INDEX idx_agendamentos_funcionario_data ON agendamentos(funcionario_id, data_hora_inicio);
@@ -0,0 +1,3 @@
+-- Fuso horário IANA de cada salão. Horários de funcionamento, limites do dia
+-- e mensagens de notificação são interpretados neste fuso.
+ALTER TABLE saloes ADD COLUMN fuso_horario VARCHAR(64) NOT NULL DEFAULT 'America/Sao_Paulo';
@@ -0,0 +1,17 @@
+-- Outbox de notificações: os eventos são gravados na mesma transação da
+-- alteração que os gerou e entregues ao n8n por um processo em segundo plano.
+CREATE TABLE webhook_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    salao_id INTEGER NOT NULL REFERENCES saloes(id) ON DELETE CASCADE,
+    evento VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDENTE' CHECK (status IN ('PENDENTE', 'ENVIADO', 'FALHOU')),
+    tentativas INTEGER NOT NULL DEFAULT 0,
+    proxima_tentativa_em TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ultimo_erro TEXT,
+    criado_em TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    enviado_em TIMESTAMPTZ
+);
+
+CREATE INDEX idx_webhook_outbox_pendentes ON webhook_outbox(proxima_tentativa_em) WHERE status = 'PENDENTE';
+CREATE INDEX idx_webhook_outbox_salao_status ON webhook_outbox(salao_id, status);
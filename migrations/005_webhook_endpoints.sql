@@ -0,0 +1,32 @@
+-- Endpoints de webhook de cada salão. Cada entrega é assinada com HMAC-SHA256
+-- usando o segredo do endpoint. eventos vazio significa "todos os eventos".
+CREATE TABLE webhook_endpoints (
+    id SERIAL PRIMARY KEY,
+    salao_id INTEGER NOT NULL REFERENCES saloes(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    segredo VARCHAR(255) NOT NULL,
+    eventos TEXT[] NOT NULL DEFAULT '{}',
+    ativo BOOLEAN NOT NULL DEFAULT TRUE,
+    criado_em TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_endpoints_salao ON webhook_endpoints(salao_id) WHERE ativo = TRUE;
+
+-- Cada linha da outbox passa a ser uma entrega para um endpoint.
+-- endpoint_id nulo indica a URL global do n8n (N8N_WEBHOOK_URL).
+ALTER TABLE webhook_outbox ADD COLUMN endpoint_id INTEGER REFERENCES webhook_endpoints(id) ON DELETE CASCADE;
+
+-- Log de todas as tentativas de entrega
+CREATE TABLE webhook_entregas (
+    id BIGSERIAL PRIMARY KEY,
+    outbox_id BIGINT NOT NULL REFERENCES webhook_outbox(id) ON DELETE CASCADE,
+    endpoint_id INTEGER REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    tentativa INTEGER NOT NULL,
+    status_http INTEGER,
+    sucesso BOOLEAN NOT NULL,
+    erro TEXT,
+    duracao_ms BIGINT NOT NULL,
+    criado_em TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_entregas_endpoint ON webhook_entregas(endpoint_id, id);
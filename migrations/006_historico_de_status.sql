@@ -0,0 +1,19 @@
+-- Máquina de estados do agendamento: PENDENTE → CONFIRMADO → CONCLUIDO / NAO_COMPARECEU,
+-- e qualquer status em aberto → CANCELADO.
+ALTER TABLE agendamentos DROP CONSTRAINT agendamentos_status_check;
+ALTER TABLE agendamentos ADD CONSTRAINT agendamentos_status_check
+    CHECK (status IN ('PENDENTE', 'CONFIRMADO', 'CANCELADO', 'CONCLUIDO', 'NAO_COMPARECEU'));
+
+-- Histórico de quem mudou o quê e quando em cada agendamento.
+-- alterado_por: 'salao:<id>' para o proprietário, 'cliente' ou 'sistema'.
+CREATE TABLE agendamento_historico (
+    id BIGSERIAL PRIMARY KEY,
+    agendamento_id INTEGER NOT NULL REFERENCES agendamentos(id) ON DELETE CASCADE,
+    status_anterior VARCHAR(50),
+    status_novo VARCHAR(50) NOT NULL,
+    motivo TEXT,
+    alterado_por VARCHAR(100) NOT NULL,
+    alterado_em TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_agendamento_historico_agendamento ON agendamento_historico(agendamento_id);
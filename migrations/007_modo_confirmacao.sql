@@ -0,0 +1,10 @@
+-- Modo de aprovação por salão. No modo MANUAL os novos agendamentos entram como
+-- PENDENTE e seguram o horário por minutos_reserva_pendente; depois disso, se o
+-- proprietário não confirmar, são cancelados automaticamente.
+ALTER TABLE saloes ADD COLUMN modo_confirmacao VARCHAR(20) NOT NULL DEFAULT 'AUTOMATICO'
+    CHECK (modo_confirmacao IN ('AUTOMATICO', 'MANUAL'));
+ALTER TABLE saloes ADD COLUMN minutos_reserva_pendente INTEGER NOT NULL DEFAULT 60;
+
+ALTER TABLE agendamentos ADD COLUMN expira_em TIMESTAMPTZ;
+
+CREATE INDEX idx_agendamentos_pendentes_expiracao ON agendamentos(expira_em) WHERE status = 'PENDENTE';
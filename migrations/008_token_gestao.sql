@@ -0,0 +1,6 @@
+-- Token de gestão: dá ao cliente acesso ao próprio agendamento (ver, cancelar,
+-- reagendar) por um link, sem login. Agendamentos antigos recebem um token novo.
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+ALTER TABLE agendamentos ADD COLUMN token_gestao VARCHAR(64) UNIQUE;
+UPDATE agendamentos SET token_gestao = encode(gen_random_bytes(32), 'hex') WHERE token_gestao IS NULL;
@@ -0,0 +1,12 @@
+-- Política de cancelamento por salão. O cliente só cancela ou reagenda sozinho
+-- com pelo menos antecedencia_minima_cancelamento_horas de antecedência (0 = sem
+-- limite) e no máximo max_reagendamentos vezes por agendamento. Só ficam
+-- marcados como tardios os cancelamentos do cliente dentro desse prazo, ou os
+-- que o salão registra atribuindo ao cliente; os do próprio salão não contam.
+ALTER TABLE saloes ADD COLUMN antecedencia_minima_cancelamento_horas INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE saloes ADD COLUMN max_reagendamentos INTEGER NOT NULL DEFAULT 3;
+
+ALTER TABLE agendamentos ADD COLUMN reagendamentos INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE agendamentos ADD COLUMN cancelamento_tardio BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_agendamentos_cancelamento_tardio ON agendamentos(salao_id, cliente_contato) WHERE cancelamento_tardio;
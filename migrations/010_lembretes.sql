@@ -0,0 +1,16 @@
+-- Lembretes antes do agendamento. Cada salão define as antecedências em minutos
+-- (padrão: 24h e 2h). Os lembretes são gravados na criação e no reagendamento;
+-- mudar a configuração só afeta agendamentos criados ou reagendados depois.
+ALTER TABLE saloes ADD COLUMN lembretes_minutos INTEGER[] NOT NULL DEFAULT '{1440,120}';
+
+CREATE TABLE agendamento_lembretes (
+    id BIGSERIAL PRIMARY KEY,
+    agendamento_id INTEGER NOT NULL REFERENCES agendamentos(id) ON DELETE CASCADE,
+    antecedencia_minutos INTEGER NOT NULL,
+    enviar_em TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDENTE' CHECK (status IN ('PENDENTE', 'ENVIADO', 'DESCARTADO')),
+    processado_em TIMESTAMPTZ,
+    UNIQUE (agendamento_id, antecedencia_minutos)
+);
+
+CREATE INDEX idx_agendamento_lembretes_pendentes ON agendamento_lembretes(enviar_em) WHERE status = 'PENDENTE';
@@ -0,0 +1,5 @@
+-- Ordem de exibição dos serviços na página de agendamento e validação dos
+-- valores (os serviços nunca são apagados, só desativados com ativo = FALSE).
+ALTER TABLE servicos ADD COLUMN ordem INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE servicos ADD CONSTRAINT servicos_duracao_positiva CHECK (duracao_minutos > 0);
+ALTER TABLE servicos ADD CONSTRAINT servicos_preco_nao_negativo CHECK (preco >= 0);
@@ -0,0 +1,16 @@
+-- Profissionais do salão. Instalações antigas já têm a tabela, criada à mão;
+-- nelas só são adicionadas as colunas que faltam. horarios segue o mesmo
+-- formato de saloes.horarios_funcionamento; NULL = segue o expediente do salão.
+CREATE TABLE IF NOT EXISTS funcionarios (
+    id SERIAL PRIMARY KEY,
+    salao_id INTEGER NOT NULL REFERENCES saloes(id) ON DELETE CASCADE,
+    nome VARCHAR(255) NOT NULL,
+    ativo BOOLEAN NOT NULL DEFAULT TRUE
+);
+ALTER TABLE funcionarios ADD COLUMN IF NOT EXISTS horarios JSONB;
+ALTER TABLE funcionarios ADD COLUMN IF NOT EXISTS criado_em TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS idx_funcionarios_salao ON funcionarios(salao_id);
+
+ALTER TABLE agendamentos ADD CONSTRAINT agendamentos_funcionario_fk
+    FOREIGN KEY (funcionario_id) REFERENCES funcionarios(id);
@@ -0,0 +1,12 @@
+-- Serviços que cada profissional realiza, com duração e preço próprios
+-- opcionais (NULL = valores do serviço). Um serviço sem nenhuma linha aqui
+-- continua sendo oferecido por todos os profissionais do salão.
+CREATE TABLE funcionario_servicos (
+    funcionario_id INTEGER NOT NULL REFERENCES funcionarios(id) ON DELETE CASCADE,
+    servico_id INTEGER NOT NULL REFERENCES servicos(id) ON DELETE CASCADE,
+    duracao_minutos INTEGER CHECK (duracao_minutos > 0),
+    preco DECIMAL(10, 2) CHECK (preco >= 0),
+    PRIMARY KEY (funcionario_id, servico_id)
+);
+
+CREATE INDEX idx_funcionario_servicos_servico ON funcionario_servicos(servico_id);
@@ -0,0 +1,17 @@
+-- Exceções por data: feriados, férias e horários especiais, do salão todo
+-- (funcionario_id NULL) ou de um profissional. Têm precedência sobre o
+-- expediente semanal; horario segue o formato de um dia de horarios_funcionamento.
+CREATE TABLE excecoes_horario (
+    id SERIAL PRIMARY KEY,
+    salao_id INTEGER NOT NULL REFERENCES saloes(id) ON DELETE CASCADE,
+    funcionario_id INTEGER REFERENCES funcionarios(id) ON DELETE CASCADE,
+    data DATE NOT NULL,
+    fechado BOOLEAN NOT NULL DEFAULT TRUE,
+    horario JSONB,
+    descricao VARCHAR(255) NOT NULL DEFAULT '',
+    criado_em TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (fechado OR horario IS NOT NULL)
+);
+
+-- Uma exceção por dia para o salão e para cada profissional
+CREATE UNIQUE INDEX idx_excecoes_horario_unica ON excecoes_horario(salao_id, (COALESCE(funcionario_id, 0)), data);
@@ -0,0 +1,30 @@
+-- Intervalo entre os horários oferecidos, por salão, com exceção opcional por
+-- serviço (NULL = intervalo do salão). Com minimizar_lacunas, a disponibilidade
+-- só oferece horários encostados no início/fim do expediente, nas pausas ou
+-- em outros agendamentos, evitando buracos que ninguém consegue reservar.
+ALTER TABLE saloes ADD COLUMN intervalo_slots_minutos INTEGER NOT NULL DEFAULT 15
+    CHECK (intervalo_slots_minutos IN (10, 15, 20, 30));
+ALTER TABLE saloes ADD COLUMN minimizar_lacunas BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE servicos ADD COLUMN intervalo_slots_minutos INTEGER
+    CHECK (intervalo_slots_minutos IN (10, 15, 20, 30));
+
+-- Tempo de preparação antes e de limpeza depois do serviço: bloqueiam a
+-- agenda, mas não fazem parte da duração cobrada.
+ALTER TABLE servicos ADD COLUMN buffer_antes_minutos INTEGER NOT NULL DEFAULT 0 CHECK (buffer_antes_minutos >= 0);
+ALTER TABLE servicos ADD COLUMN buffer_depois_minutos INTEGER NOT NULL DEFAULT 0 CHECK (buffer_depois_minutos >= 0);
+
+-- Trecho da agenda ocupado pelo agendamento, já com os buffers do serviço no
+-- momento da reserva. A proteção contra sobreposição passa a usar este trecho.
+ALTER TABLE agendamentos ADD COLUMN bloqueio_inicio TIMESTAMPTZ;
+ALTER TABLE agendamentos ADD COLUMN bloqueio_fim TIMESTAMPTZ;
+UPDATE agendamentos SET bloqueio_inicio = data_hora_inicio, bloqueio_fim = data_hora_fim;
+ALTER TABLE agendamentos ALTER COLUMN bloqueio_inicio SET NOT NULL;
+ALTER TABLE agendamentos ALTER COLUMN bloqueio_fim SET NOT NULL;
+
+ALTER TABLE agendamentos DROP CONSTRAINT agendamentos_sem_sobreposicao;
+ALTER TABLE agendamentos ADD CONSTRAINT agendamentos_sem_sobreposicao
+    EXCLUDE USING gist (
+        salao_id WITH =,
+        (COALESCE(funcionario_id, 0)) WITH =,
+        tstzrange(bloqueio_inicio, bloqueio_fim) WITH &&
+    ) WHERE (status IN ('PENDENTE', 'CONFIRMADO'));
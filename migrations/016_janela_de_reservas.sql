@@ -0,0 +1,6 @@
+-- Janela de reservas: antecedência mínima para marcar (0 = qualquer horário
+-- ainda não iniciado) e até quantos dias à frente a agenda fica aberta.
+ALTER TABLE saloes ADD COLUMN antecedencia_minima_reserva_minutos INTEGER NOT NULL DEFAULT 0
+    CHECK (antecedencia_minima_reserva_minutos >= 0);
+ALTER TABLE saloes ADD COLUMN horizonte_reserva_dias INTEGER NOT NULL DEFAULT 365
+    CHECK (horizonte_reserva_dias > 0);
@@ -0,0 +1,42 @@
+-- Agendamentos com vários serviços na mesma visita (ex.: corte + barba). Cada
+-- serviço ocupa a agenda do seu profissional, um logo após o outro; o preço é
+-- o cobrado no momento da reserva. Em agendamentos, servico_id e funcionario_id
+-- passam a indicar o primeiro serviço, e os horários cobrem a visita inteira.
+CREATE TABLE agendamento_servicos (
+    id SERIAL PRIMARY KEY,
+    agendamento_id INTEGER NOT NULL REFERENCES agendamentos(id) ON DELETE CASCADE,
+    posicao INTEGER NOT NULL CHECK (posicao > 0),
+    salao_id INTEGER NOT NULL REFERENCES saloes(id) ON DELETE CASCADE,
+    servico_id INTEGER NOT NULL REFERENCES servicos(id),
+    funcionario_id INTEGER REFERENCES funcionarios(id),
+    data_hora_inicio TIMESTAMPTZ NOT NULL,
+    data_hora_fim TIMESTAMPTZ NOT NULL,
+    bloqueio_inicio TIMESTAMPTZ NOT NULL,
+    bloqueio_fim TIMESTAMPTZ NOT NULL,
+    preco DECIMAL(10, 2) NOT NULL CHECK (preco >= 0),
+    ativo BOOLEAN NOT NULL DEFAULT TRUE, -- Acompanha o status do agendamento (PENDENTE ou CONFIRMADO)
+    UNIQUE (agendamento_id, posicao)
+);
+
+INSERT INTO agendamento_servicos (agendamento_id, posicao, salao_id, servico_id, funcionario_id,
+                                  data_hora_inicio, data_hora_fim, bloqueio_inicio, bloqueio_fim, preco, ativo)
+SELECT a.id, 1, a.salao_id, a.servico_id, a.funcionario_id,
+       a.data_hora_inicio, a.data_hora_fim, a.bloqueio_inicio, a.bloqueio_fim,
+       COALESCE(fs.preco, s.preco), a.status IN ('PENDENTE', 'CONFIRMADO')
+FROM agendamentos a
+JOIN servicos s ON s.id = a.servico_id
+LEFT JOIN funcionario_servicos fs ON fs.funcionario_id = a.funcionario_id AND fs.servico_id = a.servico_id;
+
+-- A proteção contra sobreposição passa para cada serviço da visita. É
+-- conferida ao fim de cada comando, para que um reagendamento possa mover
+-- todos os serviços da visita de uma vez sem esbarrar neles mesmos.
+ALTER TABLE agendamentos DROP CONSTRAINT agendamentos_sem_sobreposicao;
+ALTER TABLE agendamento_servicos ADD CONSTRAINT agendamento_servicos_sem_sobreposicao
+    EXCLUDE USING gist (
+        salao_id WITH =,
+        (COALESCE(funcionario_id, 0)) WITH =,
+        tstzrange(bloqueio_inicio, bloqueio_fim) WITH &&
+    ) WHERE (ativo)
+    DEFERRABLE INITIALLY IMMEDIATE;
+
+CREATE INDEX idx_agendamento_servicos_salao_bloqueio ON agendamento_servicos(salao_id, bloqueio_inicio);
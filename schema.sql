@@ -36,4 +36,7 @@ CREATE TABLE agendamentos (
 );
 
 -- Índices para otimizar buscas comuns
-CREATE INDEX idx_agendamentos_salao_data ON agendamentos(salao_id, data_hora_inicio);
\ No newline at end of file
+CREATE INDEX idx_agendamentos_salao_data ON agendamentos(salao_id, data_hora_inicio);
+
+-- As alterações posteriores ficam em migrations/, aplicadas em ordem numérica
+-- depois deste arquivo.